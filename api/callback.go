@@ -0,0 +1,98 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/serhatYilmazz/message-sender/internal/message"
+	"github.com/serhatYilmazz/message-sender/pkg/model"
+	"github.com/serhatYilmazz/message-sender/pkg/signature"
+	"strings"
+	"time"
+)
+
+// headerCallbackToken carries the shared token for providers that cannot set Authorization.
+const headerCallbackToken = "X-Callback-Token"
+
+// ReceiveDeliveryReceipt godoc
+// @Summary Receive delivery receipt
+// @Description Callback for the SMS provider to report the handset status of a message it accepted. The caller signs the body like outgoing webhooks (X-Timestamp, X-Signature) or sends the shared token as a bearer token or in X-Callback-Token
+// @Tags callbacks
+// @Accept json
+// @Produce json
+// @Param request body model.DeliveryReceiptRequest true "Delivery receipt"
+// @Success 200 {object} model.Response
+// @Failure 400 {object} model.Response
+// @Failure 401 {object} model.Response
+// @Failure 404 {object} model.Response
+// @Failure 500 {object} model.Response
+// @Router /api/webhook-callbacks/dlr [post]
+func (m MessageHandler) ReceiveDeliveryReceipt(ctx *fiber.Ctx) error {
+	if err := m.authenticateCallback(ctx); err != nil {
+		m.logger.WithError(err).Warn("rejected delivery receipt callback")
+		return ctx.Status(fiber.StatusUnauthorized).JSON(&model.Response{
+			Code:    401,
+			Message: "unauthorized",
+		})
+	}
+
+	var request model.DeliveryReceiptRequest
+	if err := json.Unmarshal(ctx.Body(), &request); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&model.Response{
+			Code:    400,
+			Message: "invalid request body",
+		})
+	}
+
+	if err := model.Validator.Struct(request); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&model.Response{
+			Code:    400,
+			Message: "validation failed: " + strings.Join(model.ValidationError(err), ", "),
+		})
+	}
+
+	if err := m.MessageService.ApplyDeliveryReceipt(ctx.Context(), request); err != nil {
+		if errors.Is(err, message.ErrReceiptNotMatched) {
+			return ctx.Status(fiber.StatusNotFound).JSON(&model.Response{
+				Code:    404,
+				Message: "no message found for provider message ID",
+			})
+		}
+		return ctx.Status(fiber.StatusInternalServerError).JSON(&model.Response{
+			Code:    500,
+			Message: "internal server error",
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(&model.Response{
+		Code:    200,
+		Message: "delivery receipt accepted",
+	})
+}
+
+// authenticateCallback accepts a request signed with one of the callback secrets or carrying the
+// callback token.
+func (m MessageHandler) authenticateCallback(ctx *fiber.Ctx) error {
+	cfg := m.CallbackConfig
+	if len(cfg.Secrets) == 0 && cfg.Token == "" {
+		return errors.New("no callback secrets or token are configured")
+	}
+
+	if cfg.Token != "" {
+		token := ctx.Get(headerCallbackToken)
+		if bearer, found := strings.CutPrefix(ctx.Get(fiber.HeaderAuthorization), "Bearer "); found {
+			token = bearer
+		}
+		if token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(cfg.Token)) == 1 {
+			return nil
+		}
+	}
+
+	if len(cfg.Secrets) > 0 {
+		return signature.Verify(cfg.Secrets, ctx.Get(signature.HeaderSignature), ctx.Get(signature.HeaderTimestamp),
+			ctx.Body(), cfg.Tolerance, time.Now())
+	}
+
+	return errors.New("callback token does not match")
+}
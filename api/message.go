@@ -1,42 +1,79 @@
 package api
 
 import (
+	"errors"
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 	"github.com/serhatYilmazz/message-sender/internal/cache"
+	"github.com/serhatYilmazz/message-sender/internal/config"
 	"github.com/serhatYilmazz/message-sender/internal/message"
+	"github.com/serhatYilmazz/message-sender/internal/outbox"
 	"github.com/serhatYilmazz/message-sender/internal/scheduler"
+	"github.com/serhatYilmazz/message-sender/internal/subscription"
 	"github.com/serhatYilmazz/message-sender/pkg/model"
 	"github.com/sirupsen/logrus"
 	"github.com/swaggo/fiber-swagger"
+	"strings"
 )
 
 type MessageHandler struct {
 	MessageService          message.Service
 	SchedulerControlService scheduler.ControlService
 	CacheService            cache.Service
+	OutboxService           outbox.Service
+	SubscriptionService     subscription.Service
+	CallbackConfig          config.CallbackConfig
 	logger                  *logrus.Logger
 }
 
-func NewMessageHandler(messageService message.Service, schedulerControlService scheduler.ControlService, cacheService cache.Service, logger *logrus.Logger) {
+func NewMessageHandler(messageService message.Service, schedulerControlService scheduler.ControlService, cacheService cache.Service, outboxService outbox.Service, subscriptionService subscription.Service, callbackConfig config.CallbackConfig, logger *logrus.Logger) {
 	messageHandler := MessageHandler{
 		MessageService:          messageService,
 		SchedulerControlService: schedulerControlService,
 		CacheService:            cacheService,
+		OutboxService:           outboxService,
+		SubscriptionService:     subscriptionService,
+		CallbackConfig:          callbackConfig,
 		logger:                  logger,
 	}
 	app := fiber.New()
 
 	api := app.Group("/api/messages")
 	apiWebhook := app.Group("/api/webhook-delivery")
+	apiDeadLetters := app.Group("/api/outbox/dead-letters")
+	apiSubscriptions := app.Group("/api/webhook-subscriptions")
+	apiCallbacks := app.Group("/api/webhook-callbacks")
+	apiScheduler := app.Group("/api/scheduler")
 
-	api.Get("", messageHandler.FindAllMessages)
+	api.Get("", messageHandler.FindMessages)
 	api.Post("", messageHandler.AddMessage)
 	api.Post("/process-message-sender", messageHandler.ProcessMessageSender)
 	api.Get("/scheduler-status", messageHandler.GetSchedulerStatus)
+	api.Get("/:id", messageHandler.FindMessageById)
+	api.Get("/:id/events", messageHandler.GetMessageEvents)
 
+	apiWebhook.Get("", messageHandler.FindWebhookDelivery)
 	apiWebhook.Get("/:messageId", messageHandler.GetWebhookDelivery)
 
+	apiDeadLetters.Get("", messageHandler.ListDeadLetters)
+	apiDeadLetters.Get("/:id", messageHandler.GetDeadLetter)
+	apiDeadLetters.Post("/:id/requeue", messageHandler.RequeueDeadLetter)
+	apiDeadLetters.Delete("/:id", messageHandler.DiscardDeadLetter)
+
+	apiSubscriptions.Get("", messageHandler.ListSubscriptions)
+	apiSubscriptions.Post("", messageHandler.CreateSubscription)
+	apiSubscriptions.Get("/:id", messageHandler.GetSubscription)
+	apiSubscriptions.Put("/:id", messageHandler.UpdateSubscription)
+	apiSubscriptions.Delete("/:id", messageHandler.DeleteSubscription)
+
+	apiCallbacks.Post("/dlr", messageHandler.ReceiveDeliveryReceipt)
+
+	apiScheduler.Post("/pause", messageHandler.PauseScheduler)
+	apiScheduler.Post("/resume", messageHandler.ResumeScheduler)
+	apiScheduler.Post("/restart", messageHandler.RestartScheduler)
+	apiScheduler.Get("/config", messageHandler.GetSchedulerConfig)
+	apiScheduler.Patch("/config", messageHandler.UpdateSchedulerConfig)
+
 	app.Get("/*", fiberSwagger.WrapHandler)
 
 	err := app.Listen(":8080")
@@ -46,25 +83,85 @@ func NewMessageHandler(messageService message.Service, schedulerControlService s
 	}
 }
 
-// FindAllMessages godoc
-// @Summary Get all messages
-// @Description Retrieve all messages from the database
+// FindMessages godoc
+// @Summary List messages
+// @Description Retrieve messages page by page, newest first unless sort=asc
 // @Tags messages
 // @Accept json
 // @Produce json
-// @Success 200 {array} model.MessageDto
+// @Param limit query int false "Page size" default(50)
+// @Param cursor query string false "Cursor returned as nextCursor by the previous page"
+// @Param status query string false "Delivery status" Enums(pending, in_flight, delivered, retrying, dead, cancelled, expired)
+// @Param phoneNumber query string false "Recipient phone number"
+// @Param createdFrom query string false "Created at or after (RFC3339)"
+// @Param createdTo query string false "Created before (RFC3339)"
+// @Param content query string false "Substring of the message content"
+// @Param sort query string false "Sort order on creation time" Enums(asc, desc) default(desc)
+// @Success 200 {object} model.MessagePage
+// @Failure 400 {object} model.Response
 // @Failure 500 {object} model.Response
 // @Router /api/messages [get]
-func (m MessageHandler) FindAllMessages(ctx *fiber.Ctx) error {
-	messages, err := m.MessageService.FindAllMessages(ctx.Context())
+func (m MessageHandler) FindMessages(ctx *fiber.Ctx) error {
+	var request model.FindMessagesRequest
+	if err := ctx.QueryParser(&request); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&model.Response{
+			Code:    400,
+			Message: "invalid query parameters",
+		})
+	}
+
+	if err := model.Validator.Struct(request); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&model.Response{
+			Code:    400,
+			Message: "validation failed: " + strings.Join(model.ValidationError(err), ", "),
+		})
+	}
+
+	page, err := m.MessageService.FindMessages(ctx.Context(), request)
 	if err != nil {
+		if errors.Is(err, message.ErrInvalidCursor) {
+			return ctx.Status(fiber.StatusBadRequest).JSON(&model.Response{
+				Code:    400,
+				Message: "invalid cursor",
+			})
+		}
 		return ctx.Status(fiber.StatusInternalServerError).JSON(&model.Response{
 			Code:    500,
 			Message: "Failed to retrieve messages",
 		})
 	}
 
-	return ctx.Status(fiber.StatusOK).JSON(messages)
+	return ctx.Status(fiber.StatusOK).JSON(page)
+}
+
+// FindMessageById godoc
+// @Summary Get a message
+// @Description Retrieve a message together with its delivery status
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Param id path string true "Message ID"
+// @Success 200 {object} model.MessageDetailDto
+// @Failure 404 {object} model.Response
+// @Failure 500 {object} model.Response
+// @Router /api/messages/{id} [get]
+func (m MessageHandler) FindMessageById(ctx *fiber.Ctx) error {
+	message, err := m.MessageService.FindMessageById(ctx.Context(), ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(&model.Response{
+			Code:    500,
+			Message: "Failed to retrieve message",
+		})
+	}
+
+	if message == nil {
+		return ctx.Status(fiber.StatusNotFound).JSON(&model.Response{
+			Code:    404,
+			Message: "message not found",
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(message)
 }
 
 // ProcessMessageSender godoc
@@ -111,24 +208,22 @@ func (m MessageHandler) ProcessMessageSender(ctx *fiber.Ctx) error {
 
 // GetSchedulerStatus godoc
 // @Summary Get scheduler status
-// @Description Get the current status of the message scheduler
+// @Description Get the current status of the message scheduler and the webhook circuit breaker
 // @Tags messages
 // @Accept json
 // @Produce json
-// @Success 200 {object} map[string]bool
+// @Success 200 {object} model.SchedulerStatus
 // @Failure 500 {object} model.Response
 // @Router /api/messages/scheduler-status [get]
 func (m MessageHandler) GetSchedulerStatus(ctx *fiber.Ctx) error {
-	isRunning := m.SchedulerControlService.GetSchedulerStatus(ctx.Context())
+	status := m.SchedulerControlService.GetSchedulerStatus(ctx.Context())
 
-	return ctx.Status(fiber.StatusOK).JSON(map[string]bool{
-		"isRunning": isRunning,
-	})
+	return ctx.Status(fiber.StatusOK).JSON(status)
 }
 
 // AddMessage godoc
 // @Summary Add a new message
-// @Description Create a new message with content and recipient phone number
+// @Description Create a new message with content and recipient phone number, optionally scheduled with sendAt and expiresAt
 // @Tags messages
 // @Accept json
 // @Produce json
@@ -159,6 +254,12 @@ func (m MessageHandler) AddMessage(ctx *fiber.Ctx) error {
 
 	savedMessage, err := m.MessageService.SaveMessage(ctx.Context(), addMessageRequest)
 	if err != nil {
+		if errors.Is(err, message.ErrInvalidSchedule) {
+			return ctx.Status(fiber.StatusBadRequest).JSON(&model.Response{
+				Code:    400,
+				Message: "validation failed: " + err.Error(),
+			})
+		}
 		return ctx.Status(fiber.StatusInternalServerError).JSON(&model.Response{
 			Code:    500,
 			Message: "internal server error",
@@ -167,9 +268,57 @@ func (m MessageHandler) AddMessage(ctx *fiber.Ctx) error {
 	return ctx.Status(fiber.StatusCreated).JSON(savedMessage)
 }
 
+// FindWebhookDelivery godoc
+// @Summary Find webhook delivery record
+// @Description Retrieve webhook delivery record by our message ID or by the provider's message ID; exactly one must be given. Records are read from the delivery history when not cached
+// @Tags webhook
+// @Accept json
+// @Produce json
+// @Param messageId query string false "Message ID"
+// @Param providerMessageId query string false "Provider message ID"
+// @Success 200 {object} cache.WebhookDelivery
+// @Failure 400 {object} model.Response
+// @Failure 404 {object} model.Response
+// @Failure 500 {object} model.Response
+// @Router /api/webhook-delivery [get]
+func (m MessageHandler) FindWebhookDelivery(ctx *fiber.Ctx) error {
+	messageId := ctx.Query("messageId")
+	providerMessageId := ctx.Query("providerMessageId")
+	if (messageId == "") == (providerMessageId == "") {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&model.Response{
+			Code:    400,
+			Message: "exactly one of messageId or providerMessageId is required",
+		})
+	}
+
+	var delivery *cache.WebhookDelivery
+	var err error
+	if messageId != "" {
+		delivery, err = m.CacheService.GetDeliveryRecord(ctx.Context(), messageId)
+	} else {
+		delivery, err = m.CacheService.GetDeliveryRecordByProviderId(ctx.Context(), providerMessageId)
+	}
+	if err != nil {
+		m.logger.WithError(err).Errorf("failed to find webhook delivery for message ID: %q, provider message ID: %q", messageId, providerMessageId)
+		return ctx.Status(fiber.StatusInternalServerError).JSON(&model.Response{
+			Code:    500,
+			Message: "failed to retrieve webhook delivery record",
+		})
+	}
+
+	if delivery == nil {
+		return ctx.Status(fiber.StatusNotFound).JSON(&model.Response{
+			Code:    404,
+			Message: "webhook delivery record not found",
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(delivery)
+}
+
 // GetWebhookDelivery godoc
 // @Summary Get webhook delivery record
-// @Description Retrieve webhook delivery record by message ID from cache
+// @Description Retrieve webhook delivery record by message ID; records are read from the delivery history when not cached
 // @Tags webhook
 // @Accept json
 // @Produce json
@@ -0,0 +1,192 @@
+package api
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/serhatYilmazz/message-sender/internal/outbox"
+	"github.com/serhatYilmazz/message-sender/pkg/model"
+)
+
+const (
+	defaultDeadLetterLimit = 50
+	maxDeadLetterLimit     = 500
+)
+
+// ListDeadLetters godoc
+// @Summary List dead letters
+// @Description Retrieve outbox entries that exhausted their retries
+// @Tags outbox
+// @Accept json
+// @Produce json
+// @Param limit query int false "Maximum number of entries to return" default(50)
+// @Param offset query int false "Number of entries to skip" default(0)
+// @Success 200 {array} outbox.OutboxEntry
+// @Failure 400 {object} model.Response
+// @Failure 500 {object} model.Response
+// @Router /api/outbox/dead-letters [get]
+func (m MessageHandler) ListDeadLetters(ctx *fiber.Ctx) error {
+	limit := ctx.QueryInt("limit", defaultDeadLetterLimit)
+	offset := ctx.QueryInt("offset", 0)
+	if limit <= 0 || limit > maxDeadLetterLimit || offset < 0 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&model.Response{
+			Code:    400,
+			Message: "invalid limit or offset",
+		})
+	}
+
+	entries, err := m.OutboxService.ListDeadLetters(ctx.Context(), limit, offset)
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(&model.Response{
+			Code:    500,
+			Message: "failed to retrieve dead letters",
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(entries)
+}
+
+// GetDeadLetter godoc
+// @Summary Get dead letter
+// @Description Retrieve a dead letter with its payload and error history
+// @Tags outbox
+// @Accept json
+// @Produce json
+// @Param id path int true "Outbox entry ID"
+// @Success 200 {object} outbox.DeadLetter
+// @Failure 400 {object} model.Response
+// @Failure 404 {object} model.Response
+// @Failure 500 {object} model.Response
+// @Router /api/outbox/dead-letters/{id} [get]
+func (m MessageHandler) GetDeadLetter(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&model.Response{
+			Code:    400,
+			Message: "invalid outbox entry ID",
+		})
+	}
+
+	deadLetter, err := m.OutboxService.GetDeadLetter(ctx.Context(), int64(id))
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(&model.Response{
+			Code:    500,
+			Message: "failed to retrieve dead letter",
+		})
+	}
+
+	if deadLetter == nil {
+		return ctx.Status(fiber.StatusNotFound).JSON(&model.Response{
+			Code:    404,
+			Message: "dead letter not found",
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(deadLetter)
+}
+
+// RequeueDeadLetter godoc
+// @Summary Requeue dead letter
+// @Description Reset the attempt count of a dead letter and schedule it for immediate delivery
+// @Tags outbox
+// @Accept json
+// @Produce json
+// @Param id path int true "Outbox entry ID"
+// @Success 200 {object} model.Response
+// @Failure 400 {object} model.Response
+// @Failure 404 {object} model.Response
+// @Failure 409 {object} model.Response
+// @Failure 500 {object} model.Response
+// @Router /api/outbox/dead-letters/{id}/requeue [post]
+func (m MessageHandler) RequeueDeadLetter(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&model.Response{
+			Code:    400,
+			Message: "invalid outbox entry ID",
+		})
+	}
+
+	if err := m.OutboxService.RequeueDeadLetter(ctx.Context(), int64(id)); err != nil {
+		return deadLetterError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(&model.Response{
+		Code:    200,
+		Message: "dead letter requeued",
+	})
+}
+
+// DiscardDeadLetter godoc
+// @Summary Discard dead letter
+// @Description Cancel a dead letter so it is never delivered; its history is kept
+// @Tags outbox
+// @Accept json
+// @Produce json
+// @Param id path int true "Outbox entry ID"
+// @Success 200 {object} model.Response
+// @Failure 400 {object} model.Response
+// @Failure 404 {object} model.Response
+// @Failure 409 {object} model.Response
+// @Failure 500 {object} model.Response
+// @Router /api/outbox/dead-letters/{id} [delete]
+func (m MessageHandler) DiscardDeadLetter(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&model.Response{
+			Code:    400,
+			Message: "invalid outbox entry ID",
+		})
+	}
+
+	if err := m.OutboxService.DiscardDeadLetter(ctx.Context(), int64(id)); err != nil {
+		return deadLetterError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(&model.Response{
+		Code:    200,
+		Message: "dead letter discarded",
+	})
+}
+
+// GetMessageEvents godoc
+// @Summary Get message delivery history
+// @Description Retrieve every outbox status transition recorded for a message
+// @Tags outbox
+// @Accept json
+// @Produce json
+// @Param id path string true "Message ID"
+// @Success 200 {array} outbox.Event
+// @Failure 500 {object} model.Response
+// @Router /api/messages/{id}/events [get]
+func (m MessageHandler) GetMessageEvents(ctx *fiber.Ctx) error {
+	events, err := m.OutboxService.GetMessageEvents(ctx.Context(), ctx.Params("id"))
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(&model.Response{
+			Code:    500,
+			Message: "failed to retrieve message events",
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(events)
+}
+
+func deadLetterError(ctx *fiber.Ctx, err error) error {
+	if errors.Is(err, outbox.ErrEntryNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(&model.Response{
+			Code:    404,
+			Message: "dead letter not found",
+		})
+	}
+
+	if errors.Is(err, outbox.ErrInvalidTransition) || errors.Is(err, outbox.ErrStatusConflict) {
+		return ctx.Status(fiber.StatusConflict).JSON(&model.Response{
+			Code:    409,
+			Message: err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusInternalServerError).JSON(&model.Response{
+		Code:    500,
+		Message: "internal server error",
+	})
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/serhatYilmazz/message-sender/internal/scheduler"
+	"github.com/serhatYilmazz/message-sender/internal/settings"
+	"github.com/serhatYilmazz/message-sender/pkg/model"
+	"strings"
+)
+
+// PauseScheduler godoc
+// @Summary Pause the scheduler
+// @Description Stop dispatching outbox entries without stopping the scheduler; entries already being delivered finish normally
+// @Tags scheduler
+// @Accept json
+// @Produce json
+// @Success 200 {object} model.Response
+// @Failure 409 {object} model.Response
+// @Router /api/scheduler/pause [post]
+func (m MessageHandler) PauseScheduler(ctx *fiber.Ctx) error {
+	err := m.SchedulerControlService.PauseMessageSender(ctx.Context())
+	return m.schedulerLifecycleResponse(ctx, err, "message sender paused")
+}
+
+// ResumeScheduler godoc
+// @Summary Resume the scheduler
+// @Description Resume dispatching after a pause, starting with a batch right away
+// @Tags scheduler
+// @Accept json
+// @Produce json
+// @Success 200 {object} model.Response
+// @Failure 409 {object} model.Response
+// @Router /api/scheduler/resume [post]
+func (m MessageHandler) ResumeScheduler(ctx *fiber.Ctx) error {
+	err := m.SchedulerControlService.ResumeMessageSender(ctx.Context())
+	return m.schedulerLifecycleResponse(ctx, err, "message sender resumed")
+}
+
+// RestartScheduler godoc
+// @Summary Restart the scheduler
+// @Description Stop the scheduler, waiting for the batch in flight up to the drain timeout, and start it again
+// @Tags scheduler
+// @Accept json
+// @Produce json
+// @Success 200 {object} model.Response
+// @Failure 500 {object} model.Response
+// @Router /api/scheduler/restart [post]
+func (m MessageHandler) RestartScheduler(ctx *fiber.Ctx) error {
+	err := m.SchedulerControlService.RestartMessageSender(ctx.Context())
+	return m.schedulerLifecycleResponse(ctx, err, "message sender restarted")
+}
+
+// GetSchedulerConfig godoc
+// @Summary Get scheduler configuration
+// @Description Get the interval, batch size and timeouts the scheduler runs with on every instance
+// @Tags scheduler
+// @Accept json
+// @Produce json
+// @Success 200 {object} model.SchedulerConfigDto
+// @Failure 500 {object} model.Response
+// @Router /api/scheduler/config [get]
+func (m MessageHandler) GetSchedulerConfig(ctx *fiber.Ctx) error {
+	schedulerConfig, err := m.SchedulerControlService.GetSchedulerConfig(ctx.Context())
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(&model.Response{
+			Code:    500,
+			Message: "internal server error",
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(schedulerConfig)
+}
+
+// UpdateSchedulerConfig godoc
+// @Summary Update scheduler configuration
+// @Description Change the interval, batch size and timeouts without a restart. The values are stored in Postgres and applied by every instance from its next batch; a batch in progress is not interrupted. Omitted fields keep their value
+// @Tags scheduler
+// @Accept json
+// @Produce json
+// @Param request body model.SchedulerConfigRequest true "Scheduler settings to change"
+// @Success 200 {object} model.SchedulerConfigDto
+// @Failure 400 {object} model.Response
+// @Failure 500 {object} model.Response
+// @Router /api/scheduler/config [patch]
+func (m MessageHandler) UpdateSchedulerConfig(ctx *fiber.Ctx) error {
+	var request model.SchedulerConfigRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&model.Response{
+			Code:    400,
+			Message: "invalid request body",
+		})
+	}
+
+	if err := model.Validator.Struct(request); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&model.Response{
+			Code:    400,
+			Message: "validation failed: " + strings.Join(model.ValidationError(err), ", "),
+		})
+	}
+
+	schedulerConfig, err := m.SchedulerControlService.UpdateSchedulerConfig(ctx.Context(), request)
+	if err != nil {
+		if errors.Is(err, settings.ErrInvalidSettings) {
+			return ctx.Status(fiber.StatusBadRequest).JSON(&model.Response{
+				Code:    400,
+				Message: "validation failed: " + err.Error(),
+			})
+		}
+		return ctx.Status(fiber.StatusInternalServerError).JSON(&model.Response{
+			Code:    500,
+			Message: "internal server error",
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(schedulerConfig)
+}
+
+func (m MessageHandler) schedulerLifecycleResponse(ctx *fiber.Ctx, err error, statusMessage string) error {
+	if errors.Is(err, scheduler.ErrSchedulerNotRunning) {
+		return ctx.Status(fiber.StatusConflict).JSON(&model.Response{
+			Code:    409,
+			Message: err.Error(),
+		})
+	}
+	if err != nil {
+		m.logger.WithError(err).Error("failed to change scheduler state")
+		return ctx.Status(fiber.StatusInternalServerError).JSON(&model.Response{
+			Code:    500,
+			Message: "internal server error",
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(&model.Response{
+		Code:    200,
+		Message: statusMessage,
+	})
+}
@@ -0,0 +1,198 @@
+package api
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/serhatYilmazz/message-sender/internal/payload"
+	"github.com/serhatYilmazz/message-sender/internal/subscription"
+	"github.com/serhatYilmazz/message-sender/pkg/model"
+	"strings"
+)
+
+// ListSubscriptions godoc
+// @Summary List webhook subscriptions
+// @Description Retrieve every webhook subscription; secrets are never returned
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Success 200 {array} subscription.Subscription
+// @Failure 500 {object} model.Response
+// @Router /api/webhook-subscriptions [get]
+func (m MessageHandler) ListSubscriptions(ctx *fiber.Ctx) error {
+	subscriptions, err := m.SubscriptionService.ListSubscriptions(ctx.Context())
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(&model.Response{
+			Code:    500,
+			Message: "failed to retrieve webhook subscriptions",
+		})
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(subscriptions)
+}
+
+// GetSubscription godoc
+// @Summary Get webhook subscription
+// @Description Retrieve a single webhook subscription
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param id path int true "Subscription ID"
+// @Success 200 {object} subscription.Subscription
+// @Failure 400 {object} model.Response
+// @Failure 404 {object} model.Response
+// @Failure 500 {object} model.Response
+// @Router /api/webhook-subscriptions/{id} [get]
+func (m MessageHandler) GetSubscription(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&model.Response{
+			Code:    400,
+			Message: "invalid subscription ID",
+		})
+	}
+
+	sub, err := m.SubscriptionService.GetSubscription(ctx.Context(), int64(id))
+	if err != nil {
+		return ctx.Status(fiber.StatusInternalServerError).JSON(&model.Response{
+			Code:    500,
+			Message: "failed to retrieve webhook subscription",
+		})
+	}
+
+	if sub == nil {
+		return subscriptionError(ctx, subscription.ErrSubscriptionNotFound)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(sub)
+}
+
+// CreateSubscription godoc
+// @Summary Create webhook subscription
+// @Description Register a webhook endpoint, optionally limited to a phone prefix, a message tag or event types
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param request body model.SubscriptionRequest true "Subscription data"
+// @Success 201 {object} subscription.Subscription
+// @Failure 400 {object} model.Response
+// @Failure 500 {object} model.Response
+// @Router /api/webhook-subscriptions [post]
+func (m MessageHandler) CreateSubscription(ctx *fiber.Ctx) error {
+	request, errResponse := parseSubscriptionRequest(ctx)
+	if errResponse != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(errResponse)
+	}
+
+	sub, err := m.SubscriptionService.CreateSubscription(ctx.Context(), *request)
+	if err != nil {
+		return subscriptionError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(sub)
+}
+
+// UpdateSubscription godoc
+// @Summary Update webhook subscription
+// @Description Replace a webhook subscription; the secret is kept when omitted
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param id path int true "Subscription ID"
+// @Param request body model.SubscriptionRequest true "Subscription data"
+// @Success 200 {object} subscription.Subscription
+// @Failure 400 {object} model.Response
+// @Failure 404 {object} model.Response
+// @Failure 500 {object} model.Response
+// @Router /api/webhook-subscriptions/{id} [put]
+func (m MessageHandler) UpdateSubscription(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&model.Response{
+			Code:    400,
+			Message: "invalid subscription ID",
+		})
+	}
+
+	request, errResponse := parseSubscriptionRequest(ctx)
+	if errResponse != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(errResponse)
+	}
+
+	sub, err := m.SubscriptionService.UpdateSubscription(ctx.Context(), int64(id), *request)
+	if err != nil {
+		return subscriptionError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(sub)
+}
+
+// DeleteSubscription godoc
+// @Summary Delete webhook subscription
+// @Description Remove a webhook subscription together with its delivery records
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param id path int true "Subscription ID"
+// @Success 200 {object} model.Response
+// @Failure 400 {object} model.Response
+// @Failure 404 {object} model.Response
+// @Failure 500 {object} model.Response
+// @Router /api/webhook-subscriptions/{id} [delete]
+func (m MessageHandler) DeleteSubscription(ctx *fiber.Ctx) error {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&model.Response{
+			Code:    400,
+			Message: "invalid subscription ID",
+		})
+	}
+
+	if err := m.SubscriptionService.DeleteSubscription(ctx.Context(), int64(id)); err != nil {
+		return subscriptionError(ctx, err)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(&model.Response{
+		Code:    200,
+		Message: "webhook subscription deleted",
+	})
+}
+
+func parseSubscriptionRequest(ctx *fiber.Ctx) (*model.SubscriptionRequest, *model.Response) {
+	var request model.SubscriptionRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		return nil, &model.Response{
+			Code:    400,
+			Message: "invalid request body",
+		}
+	}
+
+	if err := model.Validator.Struct(request); err != nil {
+		return nil, &model.Response{
+			Code:    400,
+			Message: "validation failed: " + strings.Join(model.ValidationError(err), ", "),
+		}
+	}
+
+	return &request, nil
+}
+
+func subscriptionError(ctx *fiber.Ctx, err error) error {
+	if errors.Is(err, subscription.ErrSubscriptionNotFound) {
+		return ctx.Status(fiber.StatusNotFound).JSON(&model.Response{
+			Code:    404,
+			Message: "webhook subscription not found",
+		})
+	}
+
+	if errors.Is(err, payload.ErrInvalidTemplate) || errors.Is(err, payload.ErrUnknownFormat) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&model.Response{
+			Code:    400,
+			Message: "validation failed: " + err.Error(),
+		})
+	}
+
+	return ctx.Status(fiber.StatusInternalServerError).JSON(&model.Response{
+		Code:    500,
+		Message: "internal server error",
+	})
+}
@@ -17,7 +17,7 @@ const docTemplate = `{
     "paths": {
         "/api/messages": {
             "get": {
-                "description": "Retrieve all messages from the database",
+                "description": "Retrieve messages page by page, newest first unless sort=asc",
                 "consumes": [
                     "application/json"
                 ],
@@ -27,15 +27,83 @@ const docTemplate = `{
                 "tags": [
                     "messages"
                 ],
-                "summary": "Get all messages",
+                "summary": "List messages",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Page size",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor returned as nextCursor by the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "pending",
+                            "in_flight",
+                            "delivered",
+                            "retrying",
+                            "dead",
+                            "cancelled",
+                            "expired"
+                        ],
+                        "type": "string",
+                        "description": "Delivery status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Recipient phone number",
+                        "name": "phoneNumber",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Created at or after (RFC3339)",
+                        "name": "createdFrom",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Created before (RFC3339)",
+                        "name": "createdTo",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Substring of the message content",
+                        "name": "content",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "default": "desc",
+                        "description": "Sort order on creation time",
+                        "name": "sort",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/model.MessageDto"
-                            }
+                            "$ref": "#/definitions/model.MessagePage"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
                         }
                     },
                     "500": {
@@ -47,7 +115,7 @@ const docTemplate = `{
                 }
             },
             "post": {
-                "description": "Create a new message with content and recipient phone number",
+                "description": "Create a new message with content and recipient phone number, optionally scheduled with sendAt and expiresAt",
                 "consumes": [
                     "application/json"
                 ],
@@ -139,7 +207,7 @@ const docTemplate = `{
         },
         "/api/messages/scheduler-status": {
             "get": {
-                "description": "Get the current status of the message scheduler",
+                "description": "Get the current status of the message scheduler and the webhook circuit breaker",
                 "consumes": [
                     "application/json"
                 ],
@@ -154,10 +222,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "boolean"
-                            }
+                            "$ref": "#/definitions/model.SchedulerStatus"
                         }
                     },
                     "500": {
@@ -169,9 +234,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/webhook-delivery/{messageId}": {
+        "/api/messages/{id}": {
             "get": {
-                "description": "Retrieve webhook delivery record by message ID from cache",
+                "description": "Retrieve a message together with its delivery status",
                 "consumes": [
                     "application/json"
                 ],
@@ -179,14 +244,14 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "webhook"
+                    "messages"
                 ],
-                "summary": "Get webhook delivery record",
+                "summary": "Get a message",
                 "parameters": [
                     {
                         "type": "string",
                         "description": "Message ID",
-                        "name": "messageId",
+                        "name": "id",
                         "in": "path",
                         "required": true
                     }
@@ -195,7 +260,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/cache.WebhookDelivery"
+                            "$ref": "#/definitions/model.MessageDetailDto"
                         }
                     },
                     "404": {
@@ -212,71 +277,1417 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "cache.WebhookDelivery": {
-            "type": "object",
-            "properties": {
-                "deliveredAt": {
-                    "type": "string"
-                },
-                "messageId": {
-                    "type": "string"
-                },
-                "outboxMessageId": {
-                    "type": "string"
-                },
-                "response": {
-                    "type": "string"
-                }
-            }
         },
-        "model.AddMessageRequest": {
-            "type": "object",
-            "required": [
-                "content",
-                "recipientPhoneNumber"
-            ],
-            "properties": {
-                "content": {
-                    "type": "string",
-                    "maxLength": 20
-                },
-                "recipientPhoneNumber": {
-                    "type": "string"
+        "/api/messages/{id}/events": {
+            "get": {
+                "description": "Retrieve every outbox status transition recorded for a message",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "outbox"
+                ],
+                "summary": "Get message delivery history",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Message ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/outbox.Event"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    }
                 }
             }
         },
-        "model.MessageDto": {
-            "type": "object",
-            "properties": {
-                "content": {
-                    "type": "string"
-                },
-                "id": {
-                    "type": "string"
-                },
-                "phoneNumber": {
-                    "type": "string"
+        "/api/outbox/dead-letters": {
+            "get": {
+                "description": "Retrieve outbox entries that exhausted their retries",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "outbox"
+                ],
+                "summary": "List dead letters",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Maximum number of entries to return",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Number of entries to skip",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/outbox.OutboxEntry"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    }
                 }
             }
         },
-        "model.MessageSenderRequest": {
-            "type": "object",
-            "properties": {
-                "isMessageSenderEnabled": {
-                    "type": "boolean"
+        "/api/outbox/dead-letters/{id}": {
+            "get": {
+                "description": "Retrieve a dead letter with its payload and error history",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "outbox"
+                ],
+                "summary": "Get dead letter",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Outbox entry ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/outbox.DeadLetter"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Cancel a dead letter so it is never delivered; its history is kept",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "outbox"
+                ],
+                "summary": "Discard dead letter",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Outbox entry ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    }
                 }
             }
         },
-        "model.Response": {
-            "type": "object",
-            "properties": {
-                "code": {
-                    "type": "integer"
-                },
-                "message": {
+        "/api/outbox/dead-letters/{id}/requeue": {
+            "post": {
+                "description": "Reset the attempt count of a dead letter and schedule it for immediate delivery",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "outbox"
+                ],
+                "summary": "Requeue dead letter",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Outbox entry ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/scheduler/config": {
+            "get": {
+                "description": "Get the interval, batch size and timeouts the scheduler runs with on every instance",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "scheduler"
+                ],
+                "summary": "Get scheduler configuration",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.SchedulerConfigDto"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Change the interval, batch size and timeouts without a restart. The values are stored in Postgres and applied by every instance from its next batch; a batch in progress is not interrupted. Omitted fields keep their value",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "scheduler"
+                ],
+                "summary": "Update scheduler configuration",
+                "parameters": [
+                    {
+                        "description": "Scheduler settings to change",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.SchedulerConfigRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.SchedulerConfigDto"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/scheduler/pause": {
+            "post": {
+                "description": "Stop dispatching outbox entries without stopping the scheduler; entries already being delivered finish normally",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "scheduler"
+                ],
+                "summary": "Pause the scheduler",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/scheduler/restart": {
+            "post": {
+                "description": "Stop the scheduler, waiting for the batch in flight up to the drain timeout, and start it again",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "scheduler"
+                ],
+                "summary": "Restart the scheduler",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/scheduler/resume": {
+            "post": {
+                "description": "Resume dispatching after a pause, starting with a batch right away",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "scheduler"
+                ],
+                "summary": "Resume the scheduler",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/webhook-callbacks/dlr": {
+            "post": {
+                "description": "Callback for the SMS provider to report the handset status of a message it accepted. The caller signs the body like outgoing webhooks (X-Timestamp, X-Signature) or sends the shared token as a bearer token or in X-Callback-Token",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "callbacks"
+                ],
+                "summary": "Receive delivery receipt",
+                "parameters": [
+                    {
+                        "description": "Delivery receipt",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.DeliveryReceiptRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/webhook-delivery": {
+            "get": {
+                "description": "Retrieve webhook delivery record by our message ID or by the provider's message ID; exactly one must be given. Records are read from the delivery history when not cached",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhook"
+                ],
+                "summary": "Find webhook delivery record",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Message ID",
+                        "name": "messageId",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Provider message ID",
+                        "name": "providerMessageId",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/cache.WebhookDelivery"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/webhook-delivery/{messageId}": {
+            "get": {
+                "description": "Retrieve webhook delivery record by message ID; records are read from the delivery history when not cached",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhook"
+                ],
+                "summary": "Get webhook delivery record",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Message ID",
+                        "name": "messageId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/cache.WebhookDelivery"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/webhook-subscriptions": {
+            "get": {
+                "description": "Retrieve every webhook subscription; secrets are never returned",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "List webhook subscriptions",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/subscription.Subscription"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Register a webhook endpoint, optionally limited to a phone prefix, a message tag or event types",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Create webhook subscription",
+                "parameters": [
+                    {
+                        "description": "Subscription data",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.SubscriptionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/subscription.Subscription"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/webhook-subscriptions/{id}": {
+            "get": {
+                "description": "Retrieve a single webhook subscription",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Get webhook subscription",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/subscription.Subscription"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Replace a webhook subscription; the secret is kept when omitted",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Update webhook subscription",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Subscription data",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.SubscriptionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/subscription.Subscription"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Remove a webhook subscription together with its delivery records",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Delete webhook subscription",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Subscription ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.Response"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "cache.WebhookDelivery": {
+            "type": "object",
+            "properties": {
+                "deliveredAt": {
+                    "type": "string"
+                },
+                "messageId": {
+                    "type": "string"
+                },
+                "providerMessageId": {
+                    "type": "string"
+                },
+                "response": {
+                    "type": "string"
+                },
+                "subscriptionId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.AddMessageRequest": {
+            "type": "object",
+            "required": [
+                "content",
+                "recipientPhoneNumber",
+                "tags"
+            ],
+            "properties": {
+                "content": {
+                    "type": "string",
+                    "maxLength": 20
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "recipientPhoneNumber": {
+                    "type": "string"
+                },
+                "sendAt": {
+                    "type": "string"
+                },
+                "tags": {
+                    "type": "array",
+                    "maxItems": 10,
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "model.DeliveryReceiptRequest": {
+            "type": "object",
+            "required": [
+                "messageId",
+                "status"
+            ],
+            "properties": {
+                "errorCode": {
+                    "type": "string",
+                    "maxLength": 50
+                },
+                "messageId": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "delivered",
+                        "undelivered",
+                        "expired"
+                    ]
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.MessageDetailDto": {
+            "type": "object",
+            "properties": {
+                "attemptCount": {
+                    "type": "integer"
+                },
+                "content": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "deliveredAt": {
+                    "type": "string"
+                },
+                "deliveries": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.SubscriptionDeliveryDto"
+                    }
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "handsetErrorCode": {
+                    "type": "string"
+                },
+                "handsetStatus": {
+                    "type": "string"
+                },
+                "handsetStatusAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "lastAttemptAt": {
+                    "type": "string"
+                },
+                "lastError": {
+                    "type": "string"
+                },
+                "nextAttemptAt": {
+                    "type": "string"
+                },
+                "phoneNumber": {
+                    "type": "string"
+                },
+                "providerMessageId": {
+                    "type": "string"
+                },
+                "sendAt": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.MessageDto": {
+            "type": "object",
+            "properties": {
+                "content": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "handsetErrorCode": {
+                    "type": "string"
+                },
+                "handsetStatus": {
+                    "type": "string"
+                },
+                "handsetStatusAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "phoneNumber": {
+                    "type": "string"
+                },
+                "sendAt": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "model.MessagePage": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.MessageDto"
+                    }
+                },
+                "nextCursor": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.MessageSenderRequest": {
+            "type": "object",
+            "properties": {
+                "isMessageSenderEnabled": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "model.Response": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "integer"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.SchedulerConfigDto": {
+            "type": "object",
+            "properties": {
+                "batchSize": {
+                    "type": "integer"
+                },
+                "drainTimeout": {
+                    "type": "string"
+                },
+                "interval": {
+                    "type": "string"
+                },
+                "sendTimeout": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.SchedulerConfigRequest": {
+            "type": "object",
+            "properties": {
+                "batchSize": {
+                    "type": "integer",
+                    "maximum": 1000,
+                    "minimum": 1
+                },
+                "drainTimeout": {
+                    "type": "string"
+                },
+                "interval": {
+                    "type": "string"
+                },
+                "sendTimeout": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.SchedulerInstanceStatus": {
+            "type": "object",
+            "properties": {
+                "heartbeatAt": {
+                    "type": "string"
+                },
+                "instanceId": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.SchedulerLeaderStatus": {
+            "type": "object",
+            "properties": {
+                "instanceId": {
+                    "type": "string"
+                },
+                "isThisInstance": {
+                    "type": "boolean"
+                },
+                "leaseExpiresAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.SchedulerStatus": {
+            "type": "object",
+            "properties": {
+                "circuitBreakers": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "desiredEnabled": {
+                    "type": "boolean"
+                },
+                "instanceId": {
+                    "type": "string"
+                },
+                "instances": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.SchedulerInstanceStatus"
+                    }
+                },
+                "isRunning": {
+                    "type": "boolean"
+                },
+                "leader": {
+                    "$ref": "#/definitions/model.SchedulerLeaderStatus"
+                },
+                "state": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.SubscriptionDeliveryDto": {
+            "type": "object",
+            "properties": {
+                "attemptCount": {
+                    "type": "integer"
+                },
+                "deliveredAt": {
+                    "type": "string"
+                },
+                "lastAttemptAt": {
+                    "type": "string"
+                },
+                "lastError": {
+                    "type": "string"
+                },
+                "providerMessageId": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "subscriptionId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.SubscriptionRequest": {
+            "type": "object",
+            "required": [
+                "eventTypes",
+                "url"
+            ],
+            "properties": {
+                "enabled": {
+                    "type": "boolean"
+                },
+                "eventTypes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "payloadFormat": {
+                    "description": "PayloadFormat is one of default, template, cloudevents or cloudevents_binary; empty means the\nconfigured webhook.payload. PayloadTemplate is required for template.",
+                    "type": "string",
+                    "enum": [
+                        "default",
+                        "template",
+                        "cloudevents",
+                        "cloudevents_binary"
+                    ]
+                },
+                "payloadTemplate": {
+                    "type": "string",
+                    "maxLength": 4096
+                },
+                "phonePrefix": {
+                    "type": "string",
+                    "maxLength": 20
+                },
+                "secret": {
+                    "type": "string",
+                    "maxLength": 255
+                },
+                "tag": {
+                    "type": "string",
+                    "maxLength": 50
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "outbox.Attempt": {
+            "type": "object",
+            "properties": {
+                "attempt": {
+                    "type": "integer"
+                },
+                "attemptedAt": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                }
+            }
+        },
+        "outbox.DeadLetter": {
+            "type": "object",
+            "properties": {
+                "attemptCount": {
+                    "type": "integer"
+                },
+                "attempts": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/outbox.Attempt"
+                    }
+                },
+                "claimedBy": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "deadAt": {
+                    "type": "string"
+                },
+                "eventType": {
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "lastAttemptAt": {
+                    "type": "string"
+                },
+                "lastError": {
+                    "type": "string"
+                },
+                "leaseExpiresAt": {
+                    "type": "string"
+                },
+                "messageId": {
+                    "type": "string"
+                },
+                "nextAttemptAt": {
+                    "type": "string"
+                },
+                "payload": {
+                    "type": "object"
+                },
+                "providerMessageId": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/outbox.Status"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "outbox.Event": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "fromStatus": {
+                    "$ref": "#/definitions/outbox.Status"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "instanceId": {
+                    "type": "string"
+                },
+                "messageId": {
+                    "type": "string"
+                },
+                "outboxId": {
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "toStatus": {
+                    "$ref": "#/definitions/outbox.Status"
+                }
+            }
+        },
+        "outbox.OutboxEntry": {
+            "type": "object",
+            "properties": {
+                "attemptCount": {
+                    "type": "integer"
+                },
+                "claimedBy": {
+                    "type": "string"
+                },
+                "createdAt": {
+                    "type": "string"
+                },
+                "deadAt": {
+                    "type": "string"
+                },
+                "eventType": {
+                    "type": "string"
+                },
+                "expiresAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "lastAttemptAt": {
+                    "type": "string"
+                },
+                "lastError": {
+                    "type": "string"
+                },
+                "leaseExpiresAt": {
+                    "type": "string"
+                },
+                "messageId": {
+                    "type": "string"
+                },
+                "nextAttemptAt": {
+                    "type": "string"
+                },
+                "payload": {
+                    "type": "object"
+                },
+                "providerMessageId": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/outbox.Status"
+                },
+                "updatedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "outbox.Status": {
+            "type": "string",
+            "enum": [
+                "pending",
+                "in_flight",
+                "delivered",
+                "retrying",
+                "dead",
+                "cancelled",
+                "expired"
+            ],
+            "x-enum-varnames": [
+                "StatusPending",
+                "StatusInFlight",
+                "StatusDelivered",
+                "StatusRetrying",
+                "StatusDead",
+                "StatusCancelled",
+                "StatusExpired"
+            ]
+        },
+        "subscription.Subscription": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string"
+                },
+                "enabled": {
+                    "type": "boolean"
+                },
+                "eventTypes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "hasSecret": {
+                    "type": "boolean"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "payloadFormat": {
+                    "description": "PayloadFormat selects the request body format; empty means the configured webhook.payload.",
+                    "type": "string"
+                },
+                "payloadTemplate": {
+                    "type": "string"
+                },
+                "phonePrefix": {
+                    "type": "string"
+                },
+                "tag": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "url": {
                     "type": "string"
                 }
             }
@@ -1,16 +1,44 @@
 package cache
 
 import (
+	"strconv"
 	"time"
 )
 
+// WebhookDelivery records a message accepted by a webhook endpoint. MessageId is our message ID;
+// ProviderMessageId is the ID the endpoint answered with, if any.
 type WebhookDelivery struct {
-	MessageId       string    `json:"messageId"`
-	OutboxMessageId string    `json:"outboxMessageId"`
-	DeliveredAt     time.Time `json:"deliveredAt"`
-	Response        string    `json:"response,omitempty"`
+	MessageId         string    `json:"messageId"`
+	ProviderMessageId string    `json:"providerMessageId,omitempty"`
+	SubscriptionId    int64     `json:"subscriptionId,omitempty"`
+	DeliveredAt       time.Time `json:"deliveredAt"`
+	Response          string    `json:"response,omitempty"`
 }
 
 func (wd *WebhookDelivery) CacheKey() string {
-	return "webhook:delivery:" + wd.OutboxMessageId
+	return deliveryKey(wd.MessageId)
+}
+
+// ProviderKey indexes the same record under the provider message ID, so the ID a carrier reports
+// can be traced back to our message.
+func (wd *WebhookDelivery) ProviderKey() string {
+	return providerKey(wd.ProviderMessageId)
+}
+
+// ProcessedKey marks the message as delivered to one subscription, which is what the scheduler
+// checks before sending.
+func (wd *WebhookDelivery) ProcessedKey() string {
+	return processedKey(wd.MessageId, wd.SubscriptionId)
+}
+
+func deliveryKey(messageId string) string {
+	return "webhook:delivery:" + messageId
+}
+
+func providerKey(providerMessageId string) string {
+	return "webhook:provider:" + providerMessageId
+}
+
+func processedKey(messageId string, subscriptionId int64) string {
+	return "webhook:processed:" + messageId + ":" + strconv.FormatInt(subscriptionId, 10)
 }
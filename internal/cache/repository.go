@@ -8,4 +8,6 @@ import (
 type Repository interface {
 	StoreWebhookDelivery(ctx context.Context, delivery *WebhookDelivery, ttl time.Duration) error
 	GetWebhookDelivery(ctx context.Context, messageId string) (*WebhookDelivery, error)
+	GetWebhookDeliveryByProviderId(ctx context.Context, providerMessageId string) (*WebhookDelivery, error)
+	GetProcessedDelivery(ctx context.Context, messageId string, subscriptionId int64) (*WebhookDelivery, error)
 }
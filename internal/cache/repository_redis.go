@@ -24,22 +24,28 @@ func NewRedisRepository(client *redisClient.Client, logger *logrus.Logger) Repos
 }
 
 func (r *redisRepository) StoreWebhookDelivery(ctx context.Context, delivery *WebhookDelivery, ttl time.Duration) error {
-	key := delivery.CacheKey()
-
 	data, err := json.Marshal(delivery)
 	if err != nil {
 		r.logger.WithContext(ctx).WithError(err).Errorf("failed to marshal webhook delivery for message ID: %s", delivery.MessageId)
 		return fmt.Errorf("failed to marshal webhook delivery: %w", err)
 	}
 
-	if err := r.client.Set(ctx, key, data, ttl).Err(); err != nil {
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, delivery.CacheKey(), data, ttl)
+		pipe.Set(ctx, delivery.ProcessedKey(), data, ttl)
+		if delivery.ProviderMessageId != "" {
+			pipe.Set(ctx, delivery.ProviderKey(), data, ttl)
+		}
+		return nil
+	})
+	if err != nil {
 		r.logger.WithContext(ctx).WithError(err).Errorf("failed to store webhook delivery in Redis for message ID: %s", delivery.MessageId)
 		return fmt.Errorf("failed to store webhook delivery in Redis: %w", err)
 	}
 
 	r.logger.WithContext(ctx).
 		WithField("message_id", delivery.MessageId).
-		WithField("outbox_id", delivery.OutboxMessageId).
+		WithField("provider_message_id", delivery.ProviderMessageId).
 		WithField("ttl", ttl).
 		Debug("successfully stored webhook delivery in cache")
 
@@ -47,8 +53,18 @@ func (r *redisRepository) StoreWebhookDelivery(ctx context.Context, delivery *We
 }
 
 func (r *redisRepository) GetWebhookDelivery(ctx context.Context, messageId string) (*WebhookDelivery, error) {
-	key := "webhook:delivery:" + messageId
+	return r.getDelivery(ctx, deliveryKey(messageId), messageId)
+}
+
+func (r *redisRepository) GetWebhookDeliveryByProviderId(ctx context.Context, providerMessageId string) (*WebhookDelivery, error) {
+	return r.getDelivery(ctx, providerKey(providerMessageId), providerMessageId)
+}
+
+func (r *redisRepository) GetProcessedDelivery(ctx context.Context, messageId string, subscriptionId int64) (*WebhookDelivery, error) {
+	return r.getDelivery(ctx, processedKey(messageId, subscriptionId), messageId)
+}
 
+func (r *redisRepository) getDelivery(ctx context.Context, key, messageId string) (*WebhookDelivery, error) {
 	data, err := r.client.Get(ctx, key).Result()
 	if err != nil {
 		if err == redis.Nil {
@@ -6,43 +6,50 @@ import (
 	"time"
 
 	"github.com/serhatYilmazz/message-sender/internal/config"
+	"github.com/serhatYilmazz/message-sender/internal/delivery"
 	"github.com/serhatYilmazz/message-sender/internal/outbox"
 	"github.com/serhatYilmazz/message-sender/internal/webhook"
 	"github.com/sirupsen/logrus"
 )
 
 type Service interface {
-	RecordWebhookDelivery(ctx context.Context, outboxEntry outbox.OutboxEntry, webhookResponse *webhook.Response) error
+	RecordWebhookDelivery(ctx context.Context, outboxEntry outbox.OutboxEntry, subscriptionId int64, webhookResponse *webhook.Response) error
 	GetDeliveryRecord(ctx context.Context, messageId string) (*WebhookDelivery, error)
-	IsMessageProcessed(ctx context.Context, messageId string) (bool, error)
+	GetDeliveryRecordByProviderId(ctx context.Context, providerMessageId string) (*WebhookDelivery, error)
+	GetProcessedDelivery(ctx context.Context, messageId string, subscriptionId int64) (*WebhookDelivery, error)
 }
 
 type service struct {
 	repository Repository
+	history    delivery.Service
 	config     config.RedisConfig
 	logger     *logrus.Logger
 }
 
-func NewService(repository Repository, config config.RedisConfig, logger *logrus.Logger) Service {
+// NewService creates a Service that caches in Redis the deliveries kept in history.
+func NewService(repository Repository, history delivery.Service, config config.RedisConfig, logger *logrus.Logger) Service {
 	return &service{
 		repository: repository,
+		history:    history,
 		config:     config,
 		logger:     logger,
 	}
 }
 
-func (s *service) RecordWebhookDelivery(ctx context.Context, outboxEntry outbox.OutboxEntry, webhookResponse *webhook.Response) error {
+func (s *service) RecordWebhookDelivery(ctx context.Context, outboxEntry outbox.OutboxEntry, subscriptionId int64, webhookResponse *webhook.Response) error {
 	s.logger.WithContext(ctx).
 		WithField("outbox_id", outboxEntry.Id).
+		WithField("subscription_id", subscriptionId).
 		WithField("message_id", outboxEntry.MessageId).
 		WithField("webhook_response_message_id", webhookResponse.MessageId).
 		Debug("recording webhook delivery in cache")
 
 	delivery := &WebhookDelivery{
-		MessageId:       webhookResponse.MessageId,
-		OutboxMessageId: outboxEntry.MessageId,
-		DeliveredAt:     time.Now(),
-		Response:        webhookResponse.Message,
+		MessageId:         outboxEntry.MessageId,
+		ProviderMessageId: webhookResponse.MessageId,
+		SubscriptionId:    subscriptionId,
+		DeliveredAt:       time.Now(),
+		Response:          webhookResponse.Message,
 	}
 
 	if err := s.repository.StoreWebhookDelivery(ctx, delivery, s.config.TTL); err != nil {
@@ -58,46 +65,81 @@ func (s *service) RecordWebhookDelivery(ctx context.Context, outboxEntry outbox.
 	return nil
 }
 
+// GetDeliveryRecord returns the latest delivery of the message. Records missing from Redis, for
+// example because their TTL ran out, are read from the Postgres delivery history and cached again.
 func (s *service) GetDeliveryRecord(ctx context.Context, messageId string) (*WebhookDelivery, error) {
 	s.logger.WithContext(ctx).
 		WithField("message_id", messageId).
-		Debug("retrieving webhook delivery record from cache")
+		Debug("retrieving webhook delivery record")
 
-	delivery, err := s.repository.GetWebhookDelivery(ctx, messageId)
-	if err != nil {
-		s.logger.WithContext(ctx).WithError(err).Errorf("failed to retrieve webhook delivery record for message ID: %s", messageId)
-		return nil, fmt.Errorf("failed to retrieve webhook delivery record: %w", err)
-	}
-
-	if delivery == nil {
-		s.logger.WithContext(ctx).
-			WithField("message_id", messageId).
-			Debug("webhook delivery record not found in cache")
-		return nil, nil
-	}
+	return s.readThrough(ctx,
+		func() (*WebhookDelivery, error) { return s.repository.GetWebhookDelivery(ctx, messageId) },
+		func() (*delivery.Delivery, error) { return s.history.FindDelivered(ctx, messageId) })
+}
 
+// GetDeliveryRecordByProviderId looks a delivery up by the message ID the webhook endpoint
+// answered with.
+func (s *service) GetDeliveryRecordByProviderId(ctx context.Context, providerMessageId string) (*WebhookDelivery, error) {
 	s.logger.WithContext(ctx).
-		WithField("message_id", messageId).
-		Debug("successfully retrieved webhook delivery record from cache")
-
-	return delivery, nil
+		WithField("provider_message_id", providerMessageId).
+		Debug("retrieving webhook delivery record by provider message ID")
+
+	return s.readThrough(ctx,
+		func() (*WebhookDelivery, error) {
+			return s.repository.GetWebhookDeliveryByProviderId(ctx, providerMessageId)
+		},
+		func() (*delivery.Delivery, error) { return s.history.FindDeliveredByProviderId(ctx, providerMessageId) })
 }
 
-func (s *service) IsMessageProcessed(ctx context.Context, messageId string) (bool, error) {
+// GetProcessedDelivery returns the record of the message's delivery to one subscription, or nil
+// when it has not been delivered there.
+func (s *service) GetProcessedDelivery(ctx context.Context, messageId string, subscriptionId int64) (*WebhookDelivery, error) {
 	s.logger.WithContext(ctx).
 		WithField("message_id", messageId).
-		Debug("checking if message has been processed")
+		WithField("subscription_id", subscriptionId).
+		Debug("retrieving processed delivery record")
+
+	return s.readThrough(ctx,
+		func() (*WebhookDelivery, error) {
+			return s.repository.GetProcessedDelivery(ctx, messageId, subscriptionId)
+		},
+		func() (*delivery.Delivery, error) {
+			return s.history.FindDeliveredToSubscription(ctx, messageId, subscriptionId)
+		})
+}
 
-	delivery, err := s.GetDeliveryRecord(ctx, messageId)
+// readThrough tries Redis first and falls back to the delivery history, which is the source of
+// truth. A Redis error is logged and treated as a miss.
+func (s *service) readThrough(ctx context.Context, fromCache func() (*WebhookDelivery, error), fromHistory func() (*delivery.Delivery, error)) (*WebhookDelivery, error) {
+	record, err := fromCache()
 	if err != nil {
-		return false, err
+		s.logger.WithContext(ctx).WithError(err).Warn("failed to read webhook delivery record from cache, falling back to the delivery history")
+	}
+	if record != nil {
+		return record, nil
 	}
 
-	isProcessed := delivery != nil
-	s.logger.WithContext(ctx).
-		WithField("message_id", messageId).
-		WithField("is_processed", isProcessed).
-		Debug("checked message processing status")
+	attempt, err := fromHistory()
+	if err != nil {
+		s.logger.WithContext(ctx).WithError(err).Error("failed to read webhook delivery record from the delivery history")
+		return nil, fmt.Errorf("failed to retrieve webhook delivery record: %w", err)
+	}
+	if attempt == nil {
+		return nil, nil
+	}
+
+	record = &WebhookDelivery{
+		MessageId:         attempt.MessageId,
+		ProviderMessageId: attempt.ProviderMessageId,
+		SubscriptionId:    attempt.SubscriptionId,
+		DeliveredAt:       attempt.AttemptedAt,
+		Response:          attempt.ResponseBody,
+	}
+	if err := s.repository.StoreWebhookDelivery(ctx, record, s.config.TTL); err != nil {
+		s.logger.WithContext(ctx).WithError(err).
+			WithField("message_id", record.MessageId).
+			Warn("failed to cache webhook delivery record read from the delivery history")
+	}
 
-	return isProcessed, nil
+	return record, nil
 }
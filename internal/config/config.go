@@ -12,15 +12,134 @@ type DbConfig struct {
 }
 
 type WebhookConfig struct {
-	URL     string        `mapstructure:"url"`
-	Timeout time.Duration `mapstructure:"timeout"`
+	URL            string               `mapstructure:"url"`
+	Timeout        time.Duration        `mapstructure:"timeout"`
+	RateLimit      RateLimitConfig      `mapstructure:"rate_limit"`
+	CircuitBreaker CircuitBreakerConfig `mapstructure:"circuit_breaker"`
+	Signing        SigningConfig        `mapstructure:"signing"`
+	Auth           AuthConfig           `mapstructure:"auth"`
+	TLS            TLSConfig            `mapstructure:"tls"`
+	Payload        PayloadConfig        `mapstructure:"payload"`
+	Response       ResponseConfig       `mapstructure:"response"`
+}
+
+// ResponseConfig decides how a webhook response is interpreted. Status code patterns are exact
+// codes such as "409" or classes such as "4xx"; exact codes win over classes and codes matching
+// nothing are retried.
+type ResponseConfig struct {
+	MessageIdPath  string        `mapstructure:"message_id_path"`
+	SuccessCodes   []string      `mapstructure:"success_codes"`
+	RetryableCodes []string      `mapstructure:"retryable_codes"`
+	PermanentCodes []string      `mapstructure:"permanent_codes"`
+	MaxRetryAfter  time.Duration `mapstructure:"max_retry_after"`
+}
+
+// PayloadConfig is the body format used for subscriptions that do not set their own. Format is
+// one of default, template, cloudevents or cloudevents_binary.
+type PayloadConfig struct {
+	Format            string `mapstructure:"format"`
+	Template          string `mapstructure:"template"`
+	CloudEventsSource string `mapstructure:"cloudevents_source"`
+}
+
+// AuthConfig holds the credentials sent with every webhook request. Type is one of none, bearer,
+// basic, api_key or oauth2; only the fields of the selected type are used.
+type AuthConfig struct {
+	Type         string       `mapstructure:"type"`
+	Token        string       `mapstructure:"token"`
+	Username     string       `mapstructure:"username"`
+	Password     string       `mapstructure:"password"`
+	APIKeyHeader string       `mapstructure:"api_key_header"`
+	APIKey       string       `mapstructure:"api_key"`
+	OAuth2       OAuth2Config `mapstructure:"oauth2"`
+}
+
+type OAuth2Config struct {
+	TokenURL     string   `mapstructure:"token_url"`
+	ClientID     string   `mapstructure:"client_id"`
+	ClientSecret string   `mapstructure:"client_secret"`
+	Scopes       []string `mapstructure:"scopes"`
+}
+
+// TLSConfig enables mutual TLS towards the webhook endpoints: CertFile and KeyFile are the client
+// certificate and CAFile, when set, replaces the system roots for verifying the server.
+type TLSConfig struct {
+	CertFile string `mapstructure:"cert_file"`
+	KeyFile  string `mapstructure:"key_file"`
+	CAFile   string `mapstructure:"ca_file"`
+}
+
+type SigningConfig struct {
+	// Secrets are all currently active signing secrets; every request is signed with each of
+	// them so receivers can move to a new secret before the old one is retired.
+	Secrets []string `mapstructure:"secrets"`
+}
+
+type CircuitBreakerConfig struct {
+	Enabled             bool          `mapstructure:"enabled"`
+	FailureThreshold    int           `mapstructure:"failure_threshold"`
+	SuccessThreshold    int           `mapstructure:"success_threshold"`
+	OpenTimeout         time.Duration `mapstructure:"open_timeout"`
+	HalfOpenMaxRequests int           `mapstructure:"half_open_max_requests"`
+}
+
+type RateLimitConfig struct {
+	GlobalRps         float64 `mapstructure:"global_rps"`
+	GlobalBurst       int     `mapstructure:"global_burst"`
+	PerRecipientRps   float64 `mapstructure:"per_recipient_rps"`
+	PerRecipientBurst int     `mapstructure:"per_recipient_burst"`
 }
 
 type SchedulerConfig struct {
 	Interval    time.Duration `mapstructure:"interval"`
 	BatchSize   int           `mapstructure:"batch_size"`
 	SendTimeout time.Duration `mapstructure:"send_timeout"`
-	Enabled     bool          `mapstructure:"enabled"`
+	// DrainTimeout bounds how long a stop waits for the batch in flight before aborting it.
+	DrainTimeout  time.Duration `mapstructure:"drain_timeout"`
+	Enabled       bool          `mapstructure:"enabled"`
+	MaxAttempts   int           `mapstructure:"max_attempts"`
+	Backoff       BackoffConfig `mapstructure:"backoff"`
+	InstanceId    string        `mapstructure:"instance_id"`
+	LeaseDuration time.Duration `mapstructure:"lease_duration"`
+	Concurrency   int           `mapstructure:"concurrency"`
+	Mode          string        `mapstructure:"mode"`
+	Stream        StreamConfig  `mapstructure:"stream"`
+	// Listen wakes the scheduler through Postgres LISTEN/NOTIFY as soon as a message is due, at
+	// most once per NotifyDebounce; Interval then only paces the fallback sweep.
+	Listen         bool                 `mapstructure:"listen"`
+	NotifyDebounce time.Duration        `mapstructure:"notify_debounce"`
+	LeaderElection LeaderElectionConfig `mapstructure:"leader_election"`
+}
+
+// LeaderElectionConfig lets only the instance holding a Redis lease claim outbox entries. The
+// leader renews the lease every RenewInterval; when it dies, another instance takes over within
+// LeaseDuration plus RenewInterval.
+type LeaderElectionConfig struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	Key           string        `mapstructure:"key"`
+	LeaseDuration time.Duration `mapstructure:"lease_duration"`
+	RenewInterval time.Duration `mapstructure:"renew_interval"`
+}
+
+// StreamConfig configures the stream dispatch mode, in which a relay publishes claimed outbox
+// entries to a Redis Stream and consumer-group workers deliver them. Entries whose stream message
+// was delivered MaxDeliveries times are dropped from the stream and picked up again from Postgres
+// once their lease expires.
+type StreamConfig struct {
+	Key           string        `mapstructure:"key"`
+	Group         string        `mapstructure:"group"`
+	MaxLen        int64         `mapstructure:"max_len"`
+	RelayInterval time.Duration `mapstructure:"relay_interval"`
+	Block         time.Duration `mapstructure:"block"`
+	ClaimIdle     time.Duration `mapstructure:"claim_idle"`
+	MaxDeliveries int64         `mapstructure:"max_deliveries"`
+}
+
+type BackoffConfig struct {
+	Base       time.Duration `mapstructure:"base"`
+	Multiplier float64       `mapstructure:"multiplier"`
+	Jitter     float64       `mapstructure:"jitter"`
+	Cap        time.Duration `mapstructure:"cap"`
 }
 
 type RedisConfig struct {
@@ -35,9 +154,19 @@ type RedisConfig struct {
 	TTL          time.Duration `mapstructure:"ttl"`
 }
 
+// CallbackConfig authenticates the provider's inbound callbacks. A callback is accepted when it is
+// signed with one of Secrets, like outgoing webhooks, or carries Token; a callback is refused when
+// neither is configured.
+type CallbackConfig struct {
+	Secrets   []string      `mapstructure:"secrets"`
+	Token     string        `mapstructure:"token"`
+	Tolerance time.Duration `mapstructure:"tolerance"`
+}
+
 type Config struct {
 	DbConfig        DbConfig        `mapstructure:"database"`
 	WebhookConfig   WebhookConfig   `mapstructure:"webhook"`
 	SchedulerConfig SchedulerConfig `mapstructure:"scheduler"`
 	RedisConfig     RedisConfig     `mapstructure:"redis"`
+	CallbackConfig  CallbackConfig  `mapstructure:"callbacks"`
 }
@@ -1,8 +1,10 @@
 package config
 
 import (
+	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
+	"os"
 )
 
 func Load(logger *logrus.Logger, configPath string) (*Config, error) {
@@ -25,6 +27,21 @@ func Load(logger *logrus.Logger, configPath string) (*Config, error) {
 		return nil, err
 	}
 
+	if cfg.SchedulerConfig.InstanceId == "" {
+		cfg.SchedulerConfig.InstanceId = defaultInstanceId()
+	}
+
 	logger.Infof("Config loaded from: %s", v.ConfigFileUsed())
 	return &cfg, nil
 }
+
+// defaultInstanceId identifies this process when claiming outbox entries. The random suffix keeps
+// ids unique when several replicas share a hostname.
+func defaultInstanceId() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "message-sender"
+	}
+
+	return hostname + "-" + uuid.New().String()[:8]
+}
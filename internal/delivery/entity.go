@@ -0,0 +1,22 @@
+package delivery
+
+import (
+	"time"
+)
+
+// Delivery is one attempt to deliver a message to a webhook subscription. StatusCode is 0 when the
+// endpoint could not be reached.
+type Delivery struct {
+	Id                int64     `json:"id"`
+	OutboxId          int64     `json:"outboxId"`
+	MessageId         string    `json:"messageId"`
+	SubscriptionId    int64     `json:"subscriptionId"`
+	RequestHash       string    `json:"requestHash"`
+	StatusCode        int       `json:"statusCode,omitempty"`
+	LatencyMs         int64     `json:"latencyMs"`
+	ResponseBody      string    `json:"responseBody,omitempty"`
+	ProviderMessageId string    `json:"providerMessageId,omitempty"`
+	Error             string    `json:"error,omitempty"`
+	Delivered         bool      `json:"delivered"`
+	AttemptedAt       time.Time `json:"attemptedAt"`
+}
@@ -0,0 +1,12 @@
+package delivery
+
+import (
+	"context"
+)
+
+type Repository interface {
+	Save(ctx context.Context, delivery *Delivery) error
+	FindDelivered(ctx context.Context, messageId string) (*Delivery, error)
+	FindDeliveredByProviderId(ctx context.Context, providerMessageId string) (*Delivery, error)
+	FindDeliveredToSubscription(ctx context.Context, messageId string, subscriptionId int64) (*Delivery, error)
+}
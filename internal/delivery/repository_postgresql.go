@@ -0,0 +1,99 @@
+package delivery
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/sirupsen/logrus"
+)
+
+const deliveryColumns = `id, outbox_id, message_id, subscription_id, request_hash, COALESCE(status_code, 0), latency_ms, 
+						 COALESCE(response_body, ''), COALESCE(provider_message_id, ''), COALESCE(error, ''), delivered, attempted_at`
+
+type PgRepository struct {
+	Db     *sql.DB
+	Logger *logrus.Logger
+}
+
+func (r *PgRepository) Save(ctx context.Context, delivery *Delivery) error {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][Save] is called for outbox_id: %d", delivery.OutboxId)
+
+	query := `INSERT INTO webhook_deliveries (outbox_id, message_id, subscription_id, request_hash, status_code, latency_ms, 
+			                                response_body, provider_message_id, error, delivered, attempted_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			  RETURNING id`
+
+	err := r.Db.QueryRowContext(ctx, query,
+		delivery.OutboxId,
+		delivery.MessageId,
+		delivery.SubscriptionId,
+		delivery.RequestHash,
+		sql.NullInt64{Int64: int64(delivery.StatusCode), Valid: delivery.StatusCode != 0},
+		delivery.LatencyMs,
+		nullIfEmpty(delivery.ResponseBody),
+		nullIfEmpty(delivery.ProviderMessageId),
+		nullIfEmpty(delivery.Error),
+		delivery.Delivered,
+		delivery.AttemptedAt).Scan(&delivery.Id)
+	if err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Errorf("error while saving webhook delivery for outbox_id: %d", delivery.OutboxId)
+		return err
+	}
+
+	return nil
+}
+
+// FindDelivered returns the latest successful attempt for the message to any subscription.
+func (r *PgRepository) FindDelivered(ctx context.Context, messageId string) (*Delivery, error) {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][FindDelivered] is called for message_id: %s", messageId)
+
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries 
+			  WHERE message_id = $1 AND delivered 
+			  ORDER BY attempted_at DESC, id DESC 
+			  LIMIT 1`
+
+	return r.findOne(ctx, query, messageId)
+}
+
+func (r *PgRepository) FindDeliveredByProviderId(ctx context.Context, providerMessageId string) (*Delivery, error) {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][FindDeliveredByProviderId] is called for provider_message_id: %s", providerMessageId)
+
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries 
+			  WHERE provider_message_id = $1 AND delivered 
+			  ORDER BY attempted_at DESC, id DESC 
+			  LIMIT 1`
+
+	return r.findOne(ctx, query, providerMessageId)
+}
+
+func (r *PgRepository) FindDeliveredToSubscription(ctx context.Context, messageId string, subscriptionId int64) (*Delivery, error) {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][FindDeliveredToSubscription] is called for message_id: %s, subscription_id: %d", messageId, subscriptionId)
+
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries 
+			  WHERE message_id = $1 AND subscription_id = $2 AND delivered 
+			  ORDER BY attempted_at DESC, id DESC 
+			  LIMIT 1`
+
+	return r.findOne(ctx, query, messageId, subscriptionId)
+}
+
+func (r *PgRepository) findOne(ctx context.Context, query string, args ...any) (*Delivery, error) {
+	var delivery Delivery
+
+	err := r.Db.QueryRowContext(ctx, query, args...).Scan(&delivery.Id, &delivery.OutboxId, &delivery.MessageId,
+		&delivery.SubscriptionId, &delivery.RequestHash, &delivery.StatusCode, &delivery.LatencyMs, &delivery.ResponseBody,
+		&delivery.ProviderMessageId, &delivery.Error, &delivery.Delivered, &delivery.AttemptedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		r.Logger.WithContext(ctx).WithError(err).Error("error while querying webhook delivery")
+		return nil, err
+	}
+
+	return &delivery, nil
+}
+
+func nullIfEmpty(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
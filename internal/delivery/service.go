@@ -0,0 +1,74 @@
+package delivery
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxStoredBodySize bounds how much of a response body is kept per attempt.
+const MaxStoredBodySize = 4 << 10
+
+type Service interface {
+	RecordAttempt(ctx context.Context, delivery *Delivery) error
+	FindDelivered(ctx context.Context, messageId string) (*Delivery, error)
+	FindDeliveredByProviderId(ctx context.Context, providerMessageId string) (*Delivery, error)
+	FindDeliveredToSubscription(ctx context.Context, messageId string, subscriptionId int64) (*Delivery, error)
+}
+
+type service struct {
+	repository Repository
+	logger     *logrus.Logger
+}
+
+func NewService(repository Repository, logger *logrus.Logger) Service {
+	return &service{
+		repository: repository,
+		logger:     logger,
+	}
+}
+
+// RecordAttempt stores the attempt with its response body truncated to MaxStoredBodySize. It runs
+// even when ctx is already cancelled, since an attempt that reached the endpoint has to be kept.
+func (s *service) RecordAttempt(ctx context.Context, delivery *Delivery) error {
+	s.logger.WithContext(ctx).Debugf("[delivery.service][RecordAttempt] recording attempt for outbox entry: %d", delivery.OutboxId)
+
+	delivery.ResponseBody = truncate(delivery.ResponseBody, MaxStoredBodySize)
+
+	if err := s.repository.Save(context.WithoutCancel(ctx), delivery); err != nil {
+		s.logger.WithContext(ctx).WithError(err).
+			WithField("outbox_id", delivery.OutboxId).
+			WithField("subscription_id", delivery.SubscriptionId).
+			Error("failed to record webhook delivery attempt")
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) FindDelivered(ctx context.Context, messageId string) (*Delivery, error) {
+	s.logger.WithContext(ctx).Debugf("[delivery.service][FindDelivered] finding delivery of message: %s", messageId)
+	return s.repository.FindDelivered(ctx, messageId)
+}
+
+func (s *service) FindDeliveredByProviderId(ctx context.Context, providerMessageId string) (*Delivery, error) {
+	s.logger.WithContext(ctx).Debugf("[delivery.service][FindDeliveredByProviderId] finding delivery of provider message: %s", providerMessageId)
+	return s.repository.FindDeliveredByProviderId(ctx, providerMessageId)
+}
+
+func (s *service) FindDeliveredToSubscription(ctx context.Context, messageId string, subscriptionId int64) (*Delivery, error) {
+	s.logger.WithContext(ctx).Debugf("[delivery.service][FindDeliveredToSubscription] finding delivery of message: %s to subscription: %d", messageId, subscriptionId)
+	return s.repository.FindDeliveredToSubscription(ctx, messageId, subscriptionId)
+}
+
+// truncate cuts body to at most limit bytes and drops invalid UTF-8, which a TEXT column refuses.
+func truncate(body string, limit int) string {
+	if len(body) > limit {
+		body = body[:limit]
+	}
+	if !utf8.ValidString(body) {
+		body = strings.ToValidUTF8(body, "")
+	}
+	return body
+}
@@ -0,0 +1,103 @@
+package message
+
+import (
+	"encoding/base64"
+	"errors"
+	"github.com/serhatYilmazz/message-sender/pkg/model"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageLimit = 50
+	cursorTimeLayout = "2006-01-02T15:04:05.999999"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Filter narrows down FindMessages. Results are ordered by (created_at, id) and After, when set,
+// resumes right after the given position in that order.
+type Filter struct {
+	Limit       int
+	After       *Cursor
+	Status      string
+	PhoneNumber string
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	Content     string
+	Descending  bool
+}
+
+type Cursor struct {
+	CreatedAt time.Time
+	Id        string
+}
+
+// Encode renders the cursor as an opaque url-safe token. The timestamp is kept as the wall clock
+// stored in the TIMESTAMP column, so it compares exactly when sent back.
+func (c Cursor) Encode() string {
+	raw := c.CreatedAt.Format(cursorTimeLayout) + "|" + c.Id
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeCursor(token string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	createdAt, id, found := strings.Cut(string(raw), "|")
+	if !found || id == "" {
+		return nil, ErrInvalidCursor
+	}
+
+	parsed, err := time.Parse(cursorTimeLayout, createdAt)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &Cursor{CreatedAt: parsed, Id: id}, nil
+}
+
+func newFilter(request model.FindMessagesRequest) (Filter, error) {
+	filter := Filter{
+		Limit:       request.Limit,
+		Status:      request.Status,
+		PhoneNumber: request.PhoneNumber,
+		Content:     request.Content,
+		Descending:  request.Sort != "asc",
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = defaultPageLimit
+	}
+
+	if request.Cursor != "" {
+		cursor, err := DecodeCursor(request.Cursor)
+		if err != nil {
+			return Filter{}, err
+		}
+		filter.After = cursor
+	}
+
+	// created_at holds the server's local wall clock, so range bounds are compared in that zone.
+	if request.CreatedFrom != "" {
+		createdFrom, err := time.Parse(time.RFC3339, request.CreatedFrom)
+		if err != nil {
+			return Filter{}, err
+		}
+		createdFrom = createdFrom.Local()
+		filter.CreatedFrom = &createdFrom
+	}
+
+	if request.CreatedTo != "" {
+		createdTo, err := time.Parse(time.RFC3339, request.CreatedTo)
+		if err != nil {
+			return Filter{}, err
+		}
+		createdTo = createdTo.Local()
+		filter.CreatedTo = &createdTo
+	}
+
+	return filter, nil
+}
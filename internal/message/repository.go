@@ -5,12 +5,14 @@ import (
 	"database/sql"
 	"github.com/serhatYilmazz/message-sender/pkg/model"
 	"github.com/sirupsen/logrus"
+	"time"
 )
 
 type Repository interface {
-	// FindAllMessages Limit would be specified
-	FindAllMessages(ctx context.Context) ([]model.MessageDto, error)
+	FindMessages(ctx context.Context, filter Filter) ([]model.MessageDto, error)
+	FindMessageById(ctx context.Context, id string) (*model.MessageDto, error)
 	SaveMessageWithTx(ctx context.Context, tx *sql.Tx, request model.AddMessageRequest) (*model.MessageDto, error)
+	UpdateHandsetStatus(ctx context.Context, id, status string, statusAt time.Time, errorCode string) (bool, error)
 	BeginTransaction(ctx context.Context) (*sql.Tx, error)
 }
 
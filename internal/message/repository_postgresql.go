@@ -4,23 +4,76 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/serhatYilmazz/message-sender/pkg/model"
 	"github.com/sirupsen/logrus"
+	"strings"
 	"time"
 )
 
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
 type PgRepository struct {
 	Db     *sql.DB
 	Logger *logrus.Logger
 }
 
-func (r *PgRepository) FindAllMessages(ctx context.Context) ([]model.MessageDto, error) {
-	r.Logger.WithContext(ctx).Debugf("[PgRepository][FindAllMessages] is called")
-	query := "SELECT id, content, phone_number, created_at, updated_at FROM messages FOR UPDATE;"
-	rows, err := r.Db.QueryContext(ctx, query)
+// FindMessages returns at most filter.Limit messages using keyset pagination on (created_at, id).
+// The delivery status comes from the most recent outbox entry of each message.
+func (r *PgRepository) FindMessages(ctx context.Context, filter Filter) ([]model.MessageDto, error) {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][FindMessages] is called with filter: %+v", filter)
+
+	var conditions []string
+	var args []any
+	addCondition := func(condition string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, strings.ReplaceAll(condition, "?", fmt.Sprintf("$%d", len(args))))
+	}
+
+	order := "ASC"
+	comparison := ">"
+	if filter.Descending {
+		order = "DESC"
+		comparison = "<"
+	}
+
+	if filter.After != nil {
+		args = append(args, filter.After.CreatedAt, filter.After.Id)
+		conditions = append(conditions, fmt.Sprintf("(m.created_at, m.id) %s ($%d, $%d)", comparison, len(args)-1, len(args)))
+	}
+	if filter.Status != "" {
+		addCondition("o.status = ?", filter.Status)
+	}
+	if filter.PhoneNumber != "" {
+		addCondition("m.phone_number = ?", filter.PhoneNumber)
+	}
+	if filter.CreatedFrom != nil {
+		addCondition("m.created_at >= ?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		addCondition("m.created_at < ?", *filter.CreatedTo)
+	}
+	if filter.Content != "" {
+		addCondition(`m.content ILIKE '%' || ? || '%'`, likeEscaper.Replace(filter.Content))
+	}
+
+	query := `SELECT m.id, m.content, m.phone_number, m.tags, COALESCE(o.status, ''), m.handset_status, m.handset_status_at, 
+			         m.handset_error_code, m.send_at, m.expires_at, m.created_at, m.updated_at 
+			  FROM messages m 
+			  LEFT JOIN LATERAL (
+			      SELECT status FROM outbox WHERE outbox.message_id = m.id ORDER BY outbox.id DESC LIMIT 1
+			  ) o ON true`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(` ORDER BY m.created_at %s, m.id %s LIMIT $%d`, order, order, len(args))
+
+	rows, err := r.Db.QueryContext(ctx, query, args...)
 	if err != nil {
-		r.Logger.WithContext(ctx).WithError(err).Error("error while querying for all messages: ")
+		r.Logger.WithContext(ctx).WithError(err).Error("error while querying for messages: ")
 		return nil, err
 	}
 	defer closeRows(ctx, rows, r.Logger)
@@ -28,27 +81,57 @@ func (r *PgRepository) FindAllMessages(ctx context.Context) ([]model.MessageDto,
 	var messages = make([]model.MessageDto, 0)
 	for rows.Next() {
 		var message model.MessageDto
-		err := rows.Scan(&message.Id, &message.Content, &message.PhoneNumber, &message.CreatedAt, &message.UpdatedAt)
+		err := rows.Scan(&message.Id, &message.Content, &message.PhoneNumber, pq.Array(&message.Tags), &message.Status,
+			&message.HandsetStatus, &message.HandsetStatusAt, &message.HandsetErrorCode, &message.SendAt, &message.ExpiresAt,
+			&message.CreatedAt, &message.UpdatedAt)
 		if err != nil {
-			if errors.Is(err, sql.ErrNoRows) {
-				return nil, nil
-			}
-
+			r.Logger.WithContext(ctx).WithError(err).Error("error while scanning message")
 			return nil, err
 		}
 		messages = append(messages, message)
 	}
 
+	if err = rows.Err(); err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Error("error during rows iteration")
+		return nil, err
+	}
+
 	return messages, nil
 }
 
+func (r *PgRepository) FindMessageById(ctx context.Context, id string) (*model.MessageDto, error) {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][FindMessageById] is called for id: %s", id)
+	query := `SELECT id, content, phone_number, tags, handset_status, handset_status_at, handset_error_code, send_at, expires_at, 
+			         created_at, updated_at 
+			  FROM messages WHERE id = $1`
+
+	var message model.MessageDto
+	err := r.Db.QueryRowContext(ctx, query, id).Scan(&message.Id, &message.Content, &message.PhoneNumber,
+		pq.Array(&message.Tags), &message.HandsetStatus, &message.HandsetStatusAt, &message.HandsetErrorCode, &message.SendAt,
+		&message.ExpiresAt, &message.CreatedAt, &message.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		r.Logger.WithContext(ctx).WithError(err).Errorf("error while querying message for id: %s", id)
+		return nil, err
+	}
+
+	return &message, nil
+}
+
 func (r *PgRepository) SaveMessageWithTx(ctx context.Context, tx *sql.Tx, request model.AddMessageRequest) (*model.MessageDto, error) {
 	r.Logger.WithContext(ctx).Debugf("[PgRepository][SaveMessageWithTx] is called")
-	query := `INSERT INTO messages (id, content, phone_number, created_at, updated_at) 
-			  VALUES ($1, $2, $3, $4, $5)`
+	query := `INSERT INTO messages (id, content, phone_number, tags, send_at, expires_at, created_at, updated_at) 
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
 	id := uuid.New().String()
 	now := time.Now()
-	_, err := tx.ExecContext(ctx, query, id, request.Content, request.RecipientPhoneNumber, now, now)
+	tags := request.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	_, err := tx.ExecContext(ctx, query, id, request.Content, request.RecipientPhoneNumber, pq.Array(tags), request.SendAt,
+		request.ExpiresAt, now, now)
 	if err != nil {
 		r.Logger.WithContext(ctx).WithError(err).Errorf("error while saving data: %v", request)
 		return nil, err
@@ -58,11 +141,39 @@ func (r *PgRepository) SaveMessageWithTx(ctx context.Context, tx *sql.Tx, reques
 		Id:          id,
 		Content:     request.Content,
 		PhoneNumber: request.RecipientPhoneNumber,
+		Tags:        request.Tags,
+		SendAt:      request.SendAt,
+		ExpiresAt:   request.ExpiresAt,
 		CreatedAt:   now,
 		UpdatedAt:   now,
 	}, nil
 }
 
+// UpdateHandsetStatus stores the handset status of a message unless a receipt for a later point
+// in time has already been stored, since providers do not guarantee the order of receipts. It
+// reports whether the message was updated.
+func (r *PgRepository) UpdateHandsetStatus(ctx context.Context, id, status string, statusAt time.Time, errorCode string) (bool, error) {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][UpdateHandsetStatus] is called for id: %s", id)
+
+	query := `UPDATE messages 
+			  SET handset_status = $1, handset_status_at = $2, handset_error_code = $3, handset_updated_at = $4 
+			  WHERE id = $5 AND (handset_status_at IS NULL OR handset_status_at <= $2)`
+
+	result, err := r.Db.ExecContext(ctx, query, status, statusAt,
+		sql.NullString{String: errorCode, Valid: errorCode != ""}, time.Now(), id)
+	if err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Errorf("error while updating handset status for id: %s", id)
+		return false, err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return updated > 0, nil
+}
+
 func (r *PgRepository) BeginTransaction(ctx context.Context) (*sql.Tx, error) {
 	r.Logger.WithContext(ctx).Debugf("[PgRepository][BeginTransaction] is called")
 	return r.Db.BeginTx(ctx, nil)
@@ -2,38 +2,149 @@ package message
 
 import (
 	"context"
+	"errors"
+	"github.com/serhatYilmazz/message-sender/internal/cache"
 	"github.com/serhatYilmazz/message-sender/internal/outbox"
 	"github.com/serhatYilmazz/message-sender/pkg/model"
 	"github.com/sirupsen/logrus"
+	"time"
+)
+
+var (
+	ErrInvalidSchedule   = errors.New("expiresAt must be in the future and after sendAt")
+	ErrReceiptNotMatched = errors.New("no delivered message has this provider message ID")
 )
 
 type Service interface {
-	FindAllMessages(ctx context.Context) ([]model.MessageDto, error)
+	FindMessages(ctx context.Context, request model.FindMessagesRequest) (*model.MessagePage, error)
+	FindMessageById(ctx context.Context, id string) (*model.MessageDetailDto, error)
 	SaveMessage(ctx context.Context, request model.AddMessageRequest) (*model.MessageDto, error)
+	ApplyDeliveryReceipt(ctx context.Context, request model.DeliveryReceiptRequest) error
 }
 
 type service struct {
 	Repository    Repository
 	OutboxService outbox.Service
+	CacheService  cache.Service
 	Logger        *logrus.Logger
 }
 
-func NewMessageService(repository Repository, outboxService outbox.Service, logger *logrus.Logger) Service {
+func NewMessageService(repository Repository, outboxService outbox.Service, cacheService cache.Service, logger *logrus.Logger) Service {
 	return &service{
 		Repository:    repository,
 		OutboxService: outboxService,
+		CacheService:  cacheService,
 		Logger:        logger,
 	}
 }
 
-func (s *service) FindAllMessages(ctx context.Context) ([]model.MessageDto, error) {
-	s.Logger.WithContext(ctx).Debug("[message.service][FindAllMessages] is called")
-	return s.Repository.FindAllMessages(ctx)
+func (s *service) FindMessages(ctx context.Context, request model.FindMessagesRequest) (*model.MessagePage, error) {
+	s.Logger.WithContext(ctx).Debugf("[message.service][FindMessages] is called with %+v", request)
+
+	filter, err := newFilter(request)
+	if err != nil {
+		return nil, err
+	}
+
+	// One extra row tells whether another page follows without a separate count query.
+	limit := filter.Limit
+	filter.Limit = limit + 1
+
+	messages, err := s.Repository.FindMessages(ctx, filter)
+	if err != nil {
+		s.Logger.WithContext(ctx).WithError(err).Error("failed to find messages")
+		return nil, err
+	}
+
+	page := &model.MessagePage{Items: messages}
+	if len(messages) > limit {
+		page.Items = messages[:limit]
+		last := page.Items[limit-1]
+		page.NextCursor = Cursor{CreatedAt: last.CreatedAt, Id: last.Id}.Encode()
+	}
+
+	return page, nil
+}
+
+func (s *service) FindMessageById(ctx context.Context, id string) (*model.MessageDetailDto, error) {
+	s.Logger.WithContext(ctx).Debugf("[message.service][FindMessageById] is called with %s", id)
+
+	message, err := s.Repository.FindMessageById(ctx, id)
+	if err != nil {
+		s.Logger.WithContext(ctx).WithError(err).Error("failed to find message")
+		return nil, err
+	}
+
+	if message == nil {
+		return nil, nil
+	}
+
+	detail := &model.MessageDetailDto{
+		Id:               message.Id,
+		Content:          message.Content,
+		PhoneNumber:      message.PhoneNumber,
+		Tags:             message.Tags,
+		HandsetStatus:    message.HandsetStatus,
+		HandsetStatusAt:  message.HandsetStatusAt,
+		HandsetErrorCode: message.HandsetErrorCode,
+		SendAt:           message.SendAt,
+		ExpiresAt:        message.ExpiresAt,
+		CreatedAt:        message.CreatedAt,
+		UpdatedAt:        message.UpdatedAt,
+	}
+
+	entry, err := s.OutboxService.GetEntryByMessageId(ctx, id)
+	if err != nil {
+		s.Logger.WithContext(ctx).WithError(err).Error("failed to find outbox entry for message")
+		return nil, err
+	}
+
+	if entry != nil {
+		detail.Status = string(entry.Status)
+		detail.AttemptCount = entry.AttemptCount
+		detail.LastError = entry.LastError
+		detail.ProviderMessageId = entry.ProviderMessageId
+		detail.LastAttemptAt = entry.LastAttemptAt
+		if !entry.Status.IsTerminal() && entry.Status != outbox.StatusDead {
+			detail.NextAttemptAt = &entry.NextAttemptAt
+		}
+		if entry.Status == outbox.StatusDelivered {
+			detail.DeliveredAt = entry.LastAttemptAt
+		}
+
+		deliveries, err := s.OutboxService.GetSubscriptionDeliveries(ctx, entry.Id)
+		if err != nil {
+			s.Logger.WithContext(ctx).WithError(err).Error("failed to find subscription deliveries for message")
+			return nil, err
+		}
+
+		for _, delivery := range deliveries {
+			detail.Deliveries = append(detail.Deliveries, model.SubscriptionDeliveryDto{
+				SubscriptionId:    delivery.SubscriptionId,
+				Status:            string(delivery.Status),
+				AttemptCount:      delivery.AttemptCount,
+				LastError:         delivery.LastError,
+				ProviderMessageId: delivery.ProviderMessageId,
+				LastAttemptAt:     delivery.LastAttemptAt,
+				DeliveredAt:       delivery.DeliveredAt,
+			})
+		}
+	}
+
+	return detail, nil
 }
 
 func (s *service) SaveMessage(ctx context.Context, request model.AddMessageRequest) (*model.MessageDto, error) {
 	s.Logger.WithContext(ctx).Debugf("[message.service][SaveMessage] is called with %v", request)
 
+	if err := validateSchedule(request, time.Now()); err != nil {
+		return nil, err
+	}
+
+	// Timestamps are stored without a zone as the server's local wall clock, like created_at.
+	request.SendAt = localTime(request.SendAt)
+	request.ExpiresAt = localTime(request.ExpiresAt)
+
 	tx, err := s.Repository.BeginTransaction(ctx)
 	if err != nil {
 		s.Logger.WithContext(ctx).WithError(err).Error("failed to begin transaction")
@@ -53,7 +164,18 @@ func (s *service) SaveMessage(ctx context.Context, request model.AddMessageReque
 		return nil, err
 	}
 
-	err = s.OutboxService.CreateEntryForMessage(ctx, tx, savedMessage.Id, savedMessage.Content, savedMessage.PhoneNumber)
+	payload := outbox.MessagePayload{
+		Id:          savedMessage.Id,
+		Content:     savedMessage.Content,
+		PhoneNumber: savedMessage.PhoneNumber,
+		Tags:        savedMessage.Tags,
+	}
+	schedule := outbox.Schedule{
+		SendAt:    savedMessage.SendAt,
+		ExpiresAt: savedMessage.ExpiresAt,
+	}
+
+	err = s.OutboxService.CreateEntryForMessage(ctx, tx, payload, schedule)
 	if err != nil {
 		s.Logger.WithContext(ctx).WithError(err).Error("failed to create outbox entry")
 		return nil, err
@@ -68,3 +190,69 @@ func (s *service) SaveMessage(ctx context.Context, request model.AddMessageReque
 	s.Logger.WithContext(ctx).WithField("message_id", savedMessage.Id).Info("message and outbox entry saved successfully")
 	return savedMessage, nil
 }
+
+// ApplyDeliveryReceipt records the handset status a provider reports for the message it accepted
+// under request.MessageId. A receipt older than the stored status is ignored.
+func (s *service) ApplyDeliveryReceipt(ctx context.Context, request model.DeliveryReceiptRequest) error {
+	s.Logger.WithContext(ctx).Debugf("[message.service][ApplyDeliveryReceipt] is called for provider message ID: %s", request.MessageId)
+
+	delivery, err := s.CacheService.GetDeliveryRecordByProviderId(ctx, request.MessageId)
+	if err != nil {
+		s.Logger.WithContext(ctx).WithError(err).Error("failed to find delivery for receipt")
+		return err
+	}
+
+	if delivery == nil {
+		return ErrReceiptNotMatched
+	}
+
+	statusAt := time.Now()
+	if request.Timestamp != nil {
+		statusAt = *localTime(request.Timestamp)
+	}
+
+	updated, err := s.Repository.UpdateHandsetStatus(ctx, delivery.MessageId, request.Status, statusAt, request.ErrorCode)
+	if err != nil {
+		s.Logger.WithContext(ctx).WithError(err).WithField("message_id", delivery.MessageId).Error("failed to update handset status")
+		return err
+	}
+
+	if !updated {
+		s.Logger.WithContext(ctx).
+			WithField("message_id", delivery.MessageId).
+			WithField("handset_status", request.Status).
+			Info("ignored delivery receipt older than the stored handset status")
+		return nil
+	}
+
+	s.Logger.WithContext(ctx).
+		WithField("message_id", delivery.MessageId).
+		WithField("handset_status", request.Status).
+		Info("handset status updated from delivery receipt")
+	return nil
+}
+
+func validateSchedule(request model.AddMessageRequest, now time.Time) error {
+	if request.ExpiresAt == nil {
+		return nil
+	}
+
+	if !request.ExpiresAt.After(now) {
+		return ErrInvalidSchedule
+	}
+
+	if request.SendAt != nil && !request.ExpiresAt.After(*request.SendAt) {
+		return ErrInvalidSchedule
+	}
+
+	return nil
+}
+
+func localTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+
+	local := t.Local()
+	return &local
+}
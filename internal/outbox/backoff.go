@@ -0,0 +1,39 @@
+package outbox
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/serhatYilmazz/message-sender/internal/config"
+)
+
+// maxAttemptDelay caps the backoff when no cap is configured, keeping base * multiplier^attempt
+// from overflowing time.Duration after enough attempts.
+const maxAttemptDelay = 24 * time.Hour
+
+// nextAttemptDelay returns how long to wait before the given attempt (1-based count of
+// failures so far) is retried: base * multiplier^(attempt-1), spread by +/- jitter and capped.
+func nextAttemptDelay(cfg config.BackoffConfig, attempt int) time.Duration {
+	if cfg.Base <= 0 {
+		return 0
+	}
+
+	multiplier := cfg.Multiplier
+	if multiplier < 1 || math.IsNaN(multiplier) {
+		multiplier = 1
+	}
+
+	limit := float64(maxAttemptDelay)
+	if cfg.Cap > 0 {
+		limit = float64(cfg.Cap)
+	}
+
+	delay := math.Min(float64(cfg.Base)*math.Pow(multiplier, float64(attempt-1)), limit)
+
+	if cfg.Jitter > 0 {
+		delay += delay * cfg.Jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(math.Max(0, math.Min(delay, limit)))
+}
@@ -5,17 +5,86 @@ import (
 	"time"
 )
 
+// EventMessageCreated is the event type of the entry written for every new message.
+const EventMessageCreated = "message.created"
+
+// NotifyChannel is the Postgres channel notified when an entry is due right away, so schedulers
+// listening on it do not have to wait for their next tick.
+const NotifyChannel = "outbox_entries"
+
 type OutboxEntry struct {
-	Id        int64           `json:"id"`
-	MessageId string          `json:"messageId"`
-	Payload   json.RawMessage `json:"payload"`
-	Sent      bool            `json:"sent"`
-	CreatedAt time.Time       `json:"createdAt"`
-	UpdatedAt time.Time       `json:"updatedAt"`
+	Id                int64           `json:"id"`
+	MessageId         string          `json:"messageId"`
+	EventType         string          `json:"eventType"`
+	Payload           json.RawMessage `json:"payload" swaggertype:"object"`
+	Status            Status          `json:"status"`
+	AttemptCount      int             `json:"attemptCount"`
+	LastError         *string         `json:"lastError,omitempty"`
+	LastAttemptAt     *time.Time      `json:"lastAttemptAt,omitempty"`
+	NextAttemptAt     time.Time       `json:"nextAttemptAt"`
+	DeadAt            *time.Time      `json:"deadAt,omitempty"`
+	ClaimedBy         *string         `json:"claimedBy,omitempty"`
+	LeaseExpiresAt    *time.Time      `json:"leaseExpiresAt,omitempty"`
+	ProviderMessageId *string         `json:"providerMessageId,omitempty"`
+	ExpiresAt         *time.Time      `json:"expiresAt,omitempty"`
+	CreatedAt         time.Time       `json:"createdAt"`
+	UpdatedAt         time.Time       `json:"updatedAt"`
 }
 
 type MessagePayload struct {
-	Id          string `json:"id"`
-	Content     string `json:"content"`
-	PhoneNumber string `json:"phoneNumber"`
+	Id          string   `json:"id"`
+	Content     string   `json:"content"`
+	PhoneNumber string   `json:"phoneNumber"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+// Schedule controls when an entry becomes due and until when it may still be delivered. A nil
+// SendAt means immediately and a nil ExpiresAt means never.
+type Schedule struct {
+	SendAt    *time.Time
+	ExpiresAt *time.Time
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryDelivered DeliveryStatus = "delivered"
+	DeliveryFailed    DeliveryStatus = "failed"
+	// DeliveryRejected is a permanent failure; the subscription is not retried until the entry is
+	// requeued from the dead letters.
+	DeliveryRejected DeliveryStatus = "rejected"
+)
+
+// SubscriptionDelivery is the delivery state of one entry towards one webhook subscription.
+type SubscriptionDelivery struct {
+	OutboxId          int64          `json:"outboxId"`
+	SubscriptionId    int64          `json:"subscriptionId"`
+	Status            DeliveryStatus `json:"status"`
+	AttemptCount      int            `json:"attemptCount"`
+	LastError         *string        `json:"lastError,omitempty"`
+	ProviderMessageId *string        `json:"providerMessageId,omitempty"`
+	LastAttemptAt     *time.Time     `json:"lastAttemptAt,omitempty"`
+	DeliveredAt       *time.Time     `json:"deliveredAt,omitempty"`
+}
+
+type Attempt struct {
+	Attempt     int       `json:"attempt"`
+	Error       string    `json:"error"`
+	AttemptedAt time.Time `json:"attemptedAt"`
+}
+
+type DeadLetter struct {
+	OutboxEntry
+	Attempts []Attempt `json:"attempts"`
+}
+
+type Event struct {
+	Id         int64     `json:"id"`
+	OutboxId   int64     `json:"outboxId"`
+	MessageId  string    `json:"messageId"`
+	FromStatus *Status   `json:"fromStatus,omitempty"`
+	ToStatus   Status    `json:"toStatus"`
+	Reason     *string   `json:"reason,omitempty"`
+	InstanceId *string   `json:"instanceId,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
 }
@@ -0,0 +1,39 @@
+package outbox
+
+import (
+	"fmt"
+	"time"
+)
+
+type FailureClass string
+
+const (
+	// FailureRetryable goes through the retry policy like any other processor error.
+	FailureRetryable FailureClass = "retryable"
+	// FailurePermanent will not succeed on a retry, so the entry moves to dead letters right away.
+	FailurePermanent FailureClass = "permanent"
+)
+
+// DeliveryError is a processor error that tells the outbox how to treat the failure. RetryAfter,
+// when set, is the earliest time from now the receiver is willing to see the entry again.
+type DeliveryError struct {
+	Class      FailureClass
+	RetryAfter time.Duration
+	Err        error
+}
+
+func NewDeliveryError(class FailureClass, retryAfter time.Duration, err error) *DeliveryError {
+	return &DeliveryError{
+		Class:      class,
+		RetryAfter: retryAfter,
+		Err:        err,
+	}
+}
+
+func (e *DeliveryError) Error() string {
+	return fmt.Sprintf("%s delivery failure: %v", e.Class, e.Err)
+}
+
+func (e *DeliveryError) Unwrap() error {
+	return e.Err
+}
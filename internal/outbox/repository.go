@@ -3,13 +3,36 @@ package outbox
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"github.com/sirupsen/logrus"
+	"time"
+)
+
+var (
+	ErrEntryNotFound  = errors.New("outbox entry not found")
+	ErrStatusConflict = errors.New("outbox entry status changed concurrently")
 )
 
 type Repository interface {
 	SaveOutboxEntry(ctx context.Context, tx *sql.Tx, entry *OutboxEntry) error
-	GetUnsentEntries(ctx context.Context, limit int) ([]OutboxEntry, error)
-	MarkAsSent(ctx context.Context, ids []int64) error
+	NotifyEntryDue(ctx context.Context, tx *sql.Tx, id int64) error
+	FindExpiredLeases(ctx context.Context, now time.Time) ([]OutboxEntry, error)
+	ExpireLease(ctx context.Context, id int64, to Status, nextAttemptAt, expiredAt time.Time, instanceId string) (bool, error)
+	ExpireEntries(ctx context.Context, from []Status, instanceId string) (int64, error)
+	ClaimEntries(ctx context.Context, instanceId string, from []Status, limit int, leaseExpiresAt time.Time) ([]OutboxEntry, error)
+	TakeOverEntry(ctx context.Context, id int64, leaseExpiresAt time.Time, instanceId string, newLeaseExpiresAt time.Time) (*OutboxEntry, error)
+	MarkAsDelivered(ctx context.Context, id int64, providerMessageId string, instanceId string) error
+	ReleaseEntries(ctx context.Context, ids []int64, instanceId string) error
+	MarkAsFailed(ctx context.Context, id int64, to Status, lastError string, nextAttemptAt time.Time, instanceId string) error
+	Requeue(ctx context.Context, id int64, from Status, instanceId string) error
+	Cancel(ctx context.Context, id int64, from Status, reason, instanceId string) error
+	GetEntry(ctx context.Context, id int64) (*OutboxEntry, error)
+	GetLatestEntryByMessageId(ctx context.Context, messageId string) (*OutboxEntry, error)
+	GetEntriesByStatus(ctx context.Context, status Status, limit, offset int) ([]OutboxEntry, error)
+	GetAttempts(ctx context.Context, outboxId int64) ([]Attempt, error)
+	GetEvents(ctx context.Context, messageId string) ([]Event, error)
+	GetSubscriptionDeliveries(ctx context.Context, outboxId int64) ([]SubscriptionDelivery, error)
+	SaveSubscriptionDelivery(ctx context.Context, outboxId, subscriptionId int64, status DeliveryStatus, providerMessageId string, lastError *string) error
 }
 
 func closeRows(ctx context.Context, rows *sql.Rows, logger *logrus.Logger) {
@@ -3,11 +3,35 @@ package outbox
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"github.com/lib/pq"
 	"github.com/sirupsen/logrus"
+	"strconv"
 	"time"
 )
 
+const entryColumns = `id, message_id, event_type, payload, status, attempt_count, last_error, last_attempt_at, next_attempt_at, dead_at, 
+					  claimed_by, lease_expires_at, provider_message_id, expires_at, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// statusChange describes a single-entry transition. set holds extra column assignments
+// (starting with a comma) and where extra conditions (starting with AND); their placeholders are
+// numbered from $5 and bound to args.
+type statusChange struct {
+	id         int64
+	from       Status
+	to         Status
+	reason     *string
+	instanceId string
+	set        string
+	where      string
+	args       []any
+}
+
 type PgRepository struct {
 	Db     *sql.DB
 	Logger *logrus.Logger
@@ -16,13 +40,22 @@ type PgRepository struct {
 func (r *PgRepository) SaveOutboxEntry(ctx context.Context, tx *sql.Tx, entry *OutboxEntry) error {
 	r.Logger.WithContext(ctx).Debugf("[PgRepository][SaveOutboxEntry] is called for message_id: %s", entry.MessageId)
 
-	query := `INSERT INTO outbox (message_id, payload, sent, created_at, updated_at) 
-			  VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	query := `WITH inserted AS (
+			      INSERT INTO outbox (message_id, event_type, payload, status, next_attempt_at, expires_at, created_at, updated_at) 
+			      VALUES ($1, $2, $3, $4, $5, $6, $7, $8) 
+			      RETURNING id, message_id, status, created_at
+			  )
+			  INSERT INTO outbox_events (outbox_id, message_id, to_status, created_at)
+			  SELECT id, message_id, status, created_at FROM inserted
+			  RETURNING outbox_id`
 
 	err := tx.QueryRowContext(ctx, query,
 		entry.MessageId,
+		entry.EventType,
 		entry.Payload,
-		entry.Sent,
+		entry.Status,
+		entry.NextAttemptAt,
+		entry.ExpiresAt,
 		entry.CreatedAt,
 		entry.UpdatedAt).Scan(&entry.Id)
 
@@ -35,35 +68,467 @@ func (r *PgRepository) SaveOutboxEntry(ctx context.Context, tx *sql.Tx, entry *O
 	return nil
 }
 
-func (r *PgRepository) GetUnsentEntries(ctx context.Context, limit int) ([]OutboxEntry, error) {
-	r.Logger.WithContext(ctx).Debugf("[PgRepository][GetUnsentEntries] is called with limit: %d", limit)
+// NotifyEntryDue sends a notification on NotifyChannel as part of tx, so listeners only hear of
+// the entry once it is committed.
+func (r *PgRepository) NotifyEntryDue(ctx context.Context, tx *sql.Tx, id int64) error {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][NotifyEntryDue] is called for id: %d", id)
+
+	_, err := tx.ExecContext(ctx, `SELECT pg_notify($1, $2)`, NotifyChannel, strconv.FormatInt(id, 10))
+	if err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Errorf("error while notifying outbox entry for id: %d", id)
+		return err
+	}
+
+	return nil
+}
+
+// FindExpiredLeases returns the in-flight entries whose lease ran out by now; their worker most
+// likely crashed mid-batch.
+func (r *PgRepository) FindExpiredLeases(ctx context.Context, now time.Time) ([]OutboxEntry, error) {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][FindExpiredLeases] is called")
+
+	query := `SELECT ` + entryColumns + ` FROM outbox WHERE status = $1 AND lease_expires_at <= $2 ORDER BY lease_expires_at`
+
+	entries, err := r.queryEntries(ctx, query, StatusInFlight, now)
+	if err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Error("error while querying outbox entries with expired leases")
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// ExpireLease records a failed attempt for an in-flight entry whose lease ran out by expiredAt and
+// moves it to the to status. It reports false when the entry was settled or its lease renewed in
+// the meantime.
+func (r *PgRepository) ExpireLease(ctx context.Context, id int64, to Status, nextAttemptAt, expiredAt time.Time, instanceId string) (bool, error) {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][ExpireLease] is called for id: %d", id)
+
+	now := time.Now()
+	var deadAt *time.Time
+	if to == StatusDead {
+		deadAt = &now
+	}
+
+	reason := "lease_expired"
+	lastError := "lease expired before the delivery was settled"
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		attempt, err := r.changeStatus(ctx, tx, statusChange{
+			id:         id,
+			from:       StatusInFlight,
+			to:         to,
+			reason:     &reason,
+			instanceId: instanceId,
+			set: `, attempt_count = attempt_count + 1, last_error = $5, last_attempt_at = $6, next_attempt_at = $7, 
+				  dead_at = $8, claimed_by = NULL, lease_expires_at = NULL`,
+			where: ` AND lease_expires_at <= $9`,
+			args:  []any{lastError, now, nextAttemptAt, deadAt, expiredAt},
+		})
+		if err != nil {
+			return err
+		}
+
+		attemptQuery := `INSERT INTO outbox_attempts (outbox_id, attempt, error, attempted_at) VALUES ($1, $2, $3, $4)`
+		_, err = tx.ExecContext(ctx, attemptQuery, id, attempt, lastError, now)
+		return err
+	})
+	if errors.Is(err, ErrStatusConflict) || errors.Is(err, ErrEntryNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Errorf("error while expiring outbox lease for id: %d", id)
+		return false, err
+	}
+
+	r.Logger.WithContext(ctx).
+		WithField("outbox_id", id).
+		WithField("status", to).
+		Info("expired outbox entry lease")
+	return true, nil
+}
+
+// ExpireEntries moves entries in one of the from statuses whose delivery window has closed to
+// expired, so they are never sent late.
+func (r *PgRepository) ExpireEntries(ctx context.Context, from []Status, instanceId string) (int64, error) {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][ExpireEntries] is called")
+
+	query := `WITH expired AS (
+			      UPDATE outbox o 
+			      SET status = $1, claimed_by = NULL, lease_expires_at = NULL, updated_at = $2 
+			      FROM outbox previous 
+			      WHERE o.id = previous.id AND o.status = ANY($3) AND o.expires_at <= $2
+			      RETURNING o.id, o.message_id, previous.status AS previous_status
+			  )
+			  INSERT INTO outbox_events (outbox_id, message_id, from_status, to_status, reason, instance_id, created_at)
+			  SELECT id, message_id, previous_status, $1, 'delivery window expired', $4, $2 FROM expired`
+
+	result, err := r.Db.ExecContext(ctx, query, StatusExpired, time.Now(), pq.Array(statusStrings(from)), instanceId)
+	if err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Error("error while expiring outbox entries")
+		return 0, err
+	}
+
+	expired, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if expired > 0 {
+		r.Logger.WithContext(ctx).Warnf("expired %d outbox entries", expired)
+	}
+	return expired, nil
+}
+
+// ClaimEntries leases up to limit due entries in one of the from statuses to instanceId. Rows
+// locked by a concurrent claim are skipped rather than waited on.
+func (r *PgRepository) ClaimEntries(ctx context.Context, instanceId string, from []Status, limit int, leaseExpiresAt time.Time) ([]OutboxEntry, error) {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][ClaimEntries] is called by instance: %s with limit: %d", instanceId, limit)
+
+	query := `WITH candidates AS (
+			      SELECT id, status 
+			      FROM outbox 
+			      WHERE status = ANY($1) AND next_attempt_at <= $2 AND (expires_at IS NULL OR expires_at > $2)
+			      ORDER BY next_attempt_at, created_at
+			      LIMIT $3
+			      FOR UPDATE SKIP LOCKED
+			  ), claimed AS (
+			      UPDATE outbox o 
+			      SET status = $4, claimed_by = $5, lease_expires_at = $6, updated_at = $2 
+			      FROM candidates c 
+			      WHERE o.id = c.id
+			      RETURNING o.*, c.status AS previous_status
+			  ), events AS (
+			      INSERT INTO outbox_events (outbox_id, message_id, from_status, to_status, instance_id, created_at)
+			      SELECT id, message_id, previous_status, $4, $5, $2 FROM claimed
+			  )
+			  SELECT ` + entryColumns + ` FROM claimed ORDER BY next_attempt_at, created_at`
+
+	entries, err := r.queryEntries(ctx, query, pq.Array(statusStrings(from)), time.Now(), limit, StatusInFlight, instanceId, leaseExpiresAt)
+	if err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Error("error while claiming outbox entries")
+		return nil, err
+	}
+
+	r.Logger.WithContext(ctx).WithField("instance_id", instanceId).Infof("claimed %d outbox entries", len(entries))
+	return entries, nil
+}
+
+// TakeOverEntry moves the lease of an in-flight entry to instanceId, provided the lease still
+// expires at leaseExpiresAt. The expiry acts as the claim token: once the lease ran out and the
+// entry was claimed again, an old hand-off no longer matches. It returns nil when nothing matched.
+func (r *PgRepository) TakeOverEntry(ctx context.Context, id int64, leaseExpiresAt time.Time, instanceId string, newLeaseExpiresAt time.Time) (*OutboxEntry, error) {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][TakeOverEntry] is called for id: %d by instance: %s", id, instanceId)
+
+	query := `UPDATE outbox 
+			  SET claimed_by = $1, lease_expires_at = $2, updated_at = $3 
+			  WHERE id = $4 AND status = $5 AND lease_expires_at = $6 
+			  RETURNING ` + entryColumns
+
+	entries, err := r.queryEntries(ctx, query, instanceId, newLeaseExpiresAt, time.Now(), id, StatusInFlight, leaseExpiresAt)
+	if err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Errorf("error while taking over outbox entry for id: %d", id)
+		return nil, err
+	}
+
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	return &entries[0], nil
+}
+
+func (r *PgRepository) MarkAsDelivered(ctx context.Context, id int64, providerMessageId string, instanceId string) error {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][MarkAsDelivered] is called for id: %d", id)
+
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		_, err := r.changeStatus(ctx, tx, statusChange{
+			id:         id,
+			from:       StatusInFlight,
+			to:         StatusDelivered,
+			instanceId: instanceId,
+			set: `, attempt_count = attempt_count + 1, last_error = NULL, last_attempt_at = $5, provider_message_id = $6, 
+				  claimed_by = NULL, lease_expires_at = NULL`,
+			args: []any{time.Now(), sql.NullString{String: providerMessageId, Valid: providerMessageId != ""}},
+		})
+		return err
+	})
+	if errors.Is(err, ErrStatusConflict) {
+		r.Logger.WithContext(ctx).WithField("outbox_id", id).
+			Warn("outbox entry was no longer in flight when marked as delivered")
+		return nil
+	}
+	if err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Errorf("error while marking outbox entry as delivered for id: %d", id)
+		return err
+	}
+
+	r.Logger.WithContext(ctx).WithField("outbox_id", id).Info("marked outbox entry as delivered")
+	return nil
+}
+
+// ReleaseEntries hands in-flight entries of this instance back to the queue without counting an
+// attempt: entries that never failed return to pending, the others to retrying.
+func (r *PgRepository) ReleaseEntries(ctx context.Context, ids []int64, instanceId string) error {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][ReleaseEntries] is called for ids: %v", ids)
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := `WITH released AS (
+			      UPDATE outbox 
+			      SET status = CASE WHEN attempt_count = 0 THEN $1 ELSE $2 END, 
+			          claimed_by = NULL, lease_expires_at = NULL, updated_at = $3 
+			      WHERE id = ANY($4) AND status = $5 AND claimed_by = $6
+			      RETURNING id, message_id, status
+			  )
+			  INSERT INTO outbox_events (outbox_id, message_id, from_status, to_status, reason, instance_id, created_at)
+			  SELECT id, message_id, $5, status, 'released before delivery', $6, $3 FROM released`
+
+	_, err := r.Db.ExecContext(ctx, query, StatusPending, StatusRetrying, time.Now(), pq.Array(ids), StatusInFlight, instanceId)
+	if err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Errorf("error while releasing outbox entries for ids: %v", ids)
+		return err
+	}
+
+	r.Logger.WithContext(ctx).WithField("ids", ids).Info("released outbox entries")
+	return nil
+}
+
+func (r *PgRepository) MarkAsFailed(ctx context.Context, id int64, to Status, lastError string, nextAttemptAt time.Time, instanceId string) error {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][MarkAsFailed] is called for id: %d", id)
+
+	now := time.Now()
+	var deadAt *time.Time
+	if to == StatusDead {
+		deadAt = &now
+	}
+
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		attempt, err := r.changeStatus(ctx, tx, statusChange{
+			id:         id,
+			from:       StatusInFlight,
+			to:         to,
+			reason:     &lastError,
+			instanceId: instanceId,
+			set: `, attempt_count = attempt_count + 1, last_error = $5, last_attempt_at = $6, next_attempt_at = $7, 
+				  dead_at = $8, claimed_by = NULL, lease_expires_at = NULL`,
+			args: []any{lastError, now, nextAttemptAt, deadAt},
+		})
+		if err != nil {
+			return err
+		}
 
-	query := `SELECT id, message_id, payload, sent, created_at, updated_at 
+		attemptQuery := `INSERT INTO outbox_attempts (outbox_id, attempt, error, attempted_at) VALUES ($1, $2, $3, $4)`
+		_, err = tx.ExecContext(ctx, attemptQuery, id, attempt, lastError, now)
+		return err
+	})
+	if err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Errorf("error while marking outbox entry as failed for id: %d", id)
+		return err
+	}
+
+	r.Logger.WithContext(ctx).
+		WithField("outbox_id", id).
+		WithField("status", to).
+		Info("marked outbox entry as failed")
+	return nil
+}
+
+func (r *PgRepository) Requeue(ctx context.Context, id int64, from Status, instanceId string) error {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][Requeue] is called for id: %d", id)
+
+	reason := "requeued"
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		_, err := r.changeStatus(ctx, tx, statusChange{
+			id:         id,
+			from:       from,
+			to:         StatusPending,
+			reason:     &reason,
+			instanceId: instanceId,
+			set:        `, dead_at = NULL, attempt_count = 0, next_attempt_at = $5, claimed_by = NULL, lease_expires_at = NULL`,
+			args:       []any{time.Now()},
+		})
+		if err != nil {
+			return err
+		}
+
+		// Subscriptions that rejected the entry get another chance along with it.
+		_, err = tx.ExecContext(ctx, `UPDATE outbox_deliveries SET status = $1 WHERE outbox_id = $2 AND status = $3`,
+			DeliveryFailed, id, DeliveryRejected)
+		return err
+	})
+	if err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Errorf("error while requeueing outbox entry for id: %d", id)
+		return err
+	}
+
+	return nil
+}
+
+func (r *PgRepository) Cancel(ctx context.Context, id int64, from Status, reason, instanceId string) error {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][Cancel] is called for id: %d", id)
+
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		_, err := r.changeStatus(ctx, tx, statusChange{
+			id:         id,
+			from:       from,
+			to:         StatusCancelled,
+			reason:     &reason,
+			instanceId: instanceId,
+			set:        `, claimed_by = NULL, lease_expires_at = NULL`,
+		})
+		return err
+	})
+	if err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Errorf("error while cancelling outbox entry for id: %d", id)
+		return err
+	}
+
+	return nil
+}
+
+func (r *PgRepository) GetEntry(ctx context.Context, id int64) (*OutboxEntry, error) {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][GetEntry] is called for id: %d", id)
+
+	query := `SELECT ` + entryColumns + ` FROM outbox WHERE id = $1`
+
+	entry, err := scanEntry(r.Db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		r.Logger.WithContext(ctx).WithError(err).Errorf("error while querying outbox entry for id: %d", id)
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+func (r *PgRepository) GetLatestEntryByMessageId(ctx context.Context, messageId string) (*OutboxEntry, error) {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][GetLatestEntryByMessageId] is called for message_id: %s", messageId)
+
+	query := `SELECT ` + entryColumns + ` FROM outbox WHERE message_id = $1 ORDER BY id DESC LIMIT 1`
+
+	entry, err := scanEntry(r.Db.QueryRowContext(ctx, query, messageId))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		r.Logger.WithContext(ctx).WithError(err).Errorf("error while querying outbox entry for message_id: %s", messageId)
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+func (r *PgRepository) GetEntriesByStatus(ctx context.Context, status Status, limit, offset int) ([]OutboxEntry, error) {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][GetEntriesByStatus] is called with status: %s, limit: %d, offset: %d", status, limit, offset)
+
+	query := `SELECT ` + entryColumns + ` 
 			  FROM outbox 
-			  WHERE sent = false 
-			  ORDER BY created_at
-			  LIMIT $1`
+			  WHERE status = $1
+			  ORDER BY updated_at DESC, id DESC
+			  LIMIT $2 OFFSET $3`
+
+	entries, err := r.queryEntries(ctx, query, status, limit, offset)
+	if err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Errorf("error while querying %s outbox entries", status)
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func (r *PgRepository) GetAttempts(ctx context.Context, outboxId int64) ([]Attempt, error) {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][GetAttempts] is called for outbox_id: %d", outboxId)
+
+	query := `SELECT attempt, error, attempted_at FROM outbox_attempts WHERE outbox_id = $1 ORDER BY attempt`
 
-	rows, err := r.Db.QueryContext(ctx, query, limit)
+	rows, err := r.Db.QueryContext(ctx, query, outboxId)
 	if err != nil {
-		r.Logger.WithContext(ctx).WithError(err).Error("error while querying unsent outbox entries")
+		r.Logger.WithContext(ctx).WithError(err).Errorf("error while querying attempts for outbox_id: %d", outboxId)
 		return nil, err
 	}
 	defer closeRows(ctx, rows, r.Logger)
 
-	var entries []OutboxEntry
+	attempts := make([]Attempt, 0)
 	for rows.Next() {
-		var entry OutboxEntry
-		var payload []byte
+		var attempt Attempt
+		if err := rows.Scan(&attempt.Attempt, &attempt.Error, &attempt.AttemptedAt); err != nil {
+			r.Logger.WithContext(ctx).WithError(err).Error("error while scanning outbox attempt")
+			return nil, err
+		}
+		attempts = append(attempts, attempt)
+	}
+
+	if err = rows.Err(); err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Error("error during rows iteration")
+		return nil, err
+	}
 
-		err := rows.Scan(&entry.Id, &entry.MessageId, &payload, &entry.Sent, &entry.CreatedAt, &entry.UpdatedAt)
+	return attempts, nil
+}
+
+func (r *PgRepository) GetEvents(ctx context.Context, messageId string) ([]Event, error) {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][GetEvents] is called for message_id: %s", messageId)
+
+	query := `SELECT id, outbox_id, message_id, from_status, to_status, reason, instance_id, created_at 
+			  FROM outbox_events 
+			  WHERE message_id = $1 
+			  ORDER BY created_at, id`
+
+	rows, err := r.Db.QueryContext(ctx, query, messageId)
+	if err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Errorf("error while querying events for message_id: %s", messageId)
+		return nil, err
+	}
+	defer closeRows(ctx, rows, r.Logger)
+
+	events := make([]Event, 0)
+	for rows.Next() {
+		var event Event
+		err := rows.Scan(&event.Id, &event.OutboxId, &event.MessageId, &event.FromStatus, &event.ToStatus,
+			&event.Reason, &event.InstanceId, &event.CreatedAt)
 		if err != nil {
-			r.Logger.WithContext(ctx).WithError(err).Error("error while scanning outbox entry")
+			r.Logger.WithContext(ctx).WithError(err).Error("error while scanning outbox event")
 			return nil, err
 		}
+		events = append(events, event)
+	}
 
-		entry.Payload = payload
-		entries = append(entries, entry)
+	if err = rows.Err(); err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Error("error during rows iteration")
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func (r *PgRepository) GetSubscriptionDeliveries(ctx context.Context, outboxId int64) ([]SubscriptionDelivery, error) {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][GetSubscriptionDeliveries] is called for outbox_id: %d", outboxId)
+
+	query := `SELECT outbox_id, subscription_id, status, attempt_count, last_error, provider_message_id, last_attempt_at, delivered_at 
+			  FROM outbox_deliveries 
+			  WHERE outbox_id = $1 
+			  ORDER BY subscription_id`
+
+	rows, err := r.Db.QueryContext(ctx, query, outboxId)
+	if err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Errorf("error while querying subscription deliveries for outbox_id: %d", outboxId)
+		return nil, err
+	}
+	defer closeRows(ctx, rows, r.Logger)
+
+	deliveries := make([]SubscriptionDelivery, 0)
+	for rows.Next() {
+		var delivery SubscriptionDelivery
+		err := rows.Scan(&delivery.OutboxId, &delivery.SubscriptionId, &delivery.Status, &delivery.AttemptCount,
+			&delivery.LastError, &delivery.ProviderMessageId, &delivery.LastAttemptAt, &delivery.DeliveredAt)
+		if err != nil {
+			r.Logger.WithContext(ctx).WithError(err).Error("error while scanning subscription delivery")
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
 	}
 
 	if err = rows.Err(); err != nil {
@@ -71,25 +536,147 @@ func (r *PgRepository) GetUnsentEntries(ctx context.Context, limit int) ([]Outbo
 		return nil, err
 	}
 
-	r.Logger.WithContext(ctx).Infof("retrieved %d unsent outbox entries", len(entries))
-	return entries, nil
+	return deliveries, nil
 }
 
-func (r *PgRepository) MarkAsSent(ctx context.Context, ids []int64) error {
-	r.Logger.WithContext(ctx).Debugf("[PgRepository][MarkAsSent] is called for ids: %v", ids)
+// SaveSubscriptionDelivery records one attempt of delivering an entry to a subscription. A
+// delivered record never changes again.
+func (r *PgRepository) SaveSubscriptionDelivery(ctx context.Context, outboxId, subscriptionId int64, status DeliveryStatus, providerMessageId string, lastError *string) error {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][SaveSubscriptionDelivery] is called for outbox_id: %d, subscription_id: %d", outboxId, subscriptionId)
 
-	if len(ids) == 0 {
-		return nil
+	now := time.Now()
+	var deliveredAt *time.Time
+	if status == DeliveryDelivered {
+		deliveredAt = &now
 	}
 
-	query := `UPDATE outbox SET sent = true, updated_at = $1 WHERE id = ANY($2)`
+	query := `INSERT INTO outbox_deliveries (outbox_id, subscription_id, status, attempt_count, last_error, provider_message_id, 
+			                                 last_attempt_at, delivered_at) 
+			  VALUES ($1, $2, $3, 1, $4, $5, $6, $7) 
+			  ON CONFLICT (outbox_id, subscription_id) DO UPDATE 
+			  SET status = EXCLUDED.status, attempt_count = outbox_deliveries.attempt_count + 1, last_error = EXCLUDED.last_error, 
+			      provider_message_id = COALESCE(EXCLUDED.provider_message_id, outbox_deliveries.provider_message_id), 
+			      last_attempt_at = EXCLUDED.last_attempt_at, delivered_at = EXCLUDED.delivered_at 
+			  WHERE outbox_deliveries.status <> $8`
 
-	_, err := r.Db.ExecContext(ctx, query, time.Now(), pq.Array(ids))
+	_, err := r.Db.ExecContext(ctx, query, outboxId, subscriptionId, status, lastError,
+		sql.NullString{String: providerMessageId, Valid: providerMessageId != ""}, now, deliveredAt, DeliveryDelivered)
 	if err != nil {
-		r.Logger.WithContext(ctx).WithError(err).Errorf("error while marking outbox entries as sent for ids: %v", ids)
+		r.Logger.WithContext(ctx).WithError(err).
+			Errorf("error while saving subscription delivery for outbox_id: %d, subscription_id: %d", outboxId, subscriptionId)
 		return err
 	}
 
-	r.Logger.WithContext(ctx).WithField("ids", ids).Infof("marked %d outbox entries as sent", len(ids))
 	return nil
 }
+
+// changeStatus applies change inside tx and records it in outbox_events. It returns the entry's
+// attempt count after the update.
+func (r *PgRepository) changeStatus(ctx context.Context, tx *sql.Tx, change statusChange) (int, error) {
+	now := time.Now()
+	query := `UPDATE outbox SET status = $1, updated_at = $2` + change.set + ` 
+			  WHERE id = $3 AND status = $4` + change.where + ` 
+			  RETURNING message_id, attempt_count`
+
+	args := append([]any{change.to, now, change.id, change.from}, change.args...)
+
+	var messageId string
+	var attemptCount int
+	err := tx.QueryRowContext(ctx, query, args...).Scan(&messageId, &attemptCount)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, r.missingOrConflict(ctx, tx, change.id)
+		}
+		return 0, err
+	}
+
+	eventQuery := `INSERT INTO outbox_events (outbox_id, message_id, from_status, to_status, reason, instance_id, created_at) 
+				   VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err = tx.ExecContext(ctx, eventQuery, change.id, messageId, change.from, change.to, change.reason, change.instanceId, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record outbox event: %w", err)
+	}
+
+	return attemptCount, nil
+}
+
+func (r *PgRepository) missingOrConflict(ctx context.Context, tx *sql.Tx, id int64) error {
+	var exists bool
+	err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM outbox WHERE id = $1)`, id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return ErrEntryNotFound
+	}
+	return ErrStatusConflict
+}
+
+func (r *PgRepository) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer rollback(ctx, tx, r.Logger)
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *PgRepository) queryEntries(ctx context.Context, query string, args ...any) ([]OutboxEntry, error) {
+	rows, err := r.Db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(ctx, rows, r.Logger)
+
+	entries := make([]OutboxEntry, 0)
+	for rows.Next() {
+		entry, err := scanEntry(rows)
+		if err != nil {
+			r.Logger.WithContext(ctx).WithError(err).Error("error while scanning outbox entry")
+			return nil, err
+		}
+		entries = append(entries, *entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Error("error during rows iteration")
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func scanEntry(row rowScanner) (*OutboxEntry, error) {
+	var entry OutboxEntry
+	var payload []byte
+
+	err := row.Scan(&entry.Id, &entry.MessageId, &entry.EventType, &payload, &entry.Status, &entry.AttemptCount, &entry.LastError,
+		&entry.LastAttemptAt, &entry.NextAttemptAt, &entry.DeadAt, &entry.ClaimedBy, &entry.LeaseExpiresAt,
+		&entry.ProviderMessageId, &entry.ExpiresAt, &entry.CreatedAt, &entry.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	entry.Payload = payload
+	return &entry, nil
+}
+
+func statusStrings(statuses []Status) []string {
+	values := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		values = append(values, string(status))
+	}
+	return values
+}
+
+func rollback(ctx context.Context, tx *sql.Tx, logger *logrus.Logger) {
+	if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		logger.WithContext(ctx).WithError(err).Error("failed to rollback transaction")
+	}
+}
@@ -4,49 +4,82 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"github.com/serhatYilmazz/message-sender/internal/config"
+	"github.com/serhatYilmazz/message-sender/internal/settings"
 	"github.com/sirupsen/logrus"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// Processor delivers a single claimed entry and returns the message ID the provider assigned to
+// it, if any.
+type Processor func(ctx context.Context, entry OutboxEntry) (string, error)
+
 type Service interface {
-	CreateEntryForMessage(ctx context.Context, tx *sql.Tx, messageId, content, phoneNumber string) error
-	ProcessUnsentEntries(ctx context.Context, limit int, processor func(ctx context.Context, entry OutboxEntry) error) (int, error)
-	MarkEntriesAsSent(ctx context.Context, ids []int64) error
+	CreateEntryForMessage(ctx context.Context, tx *sql.Tx, payload MessagePayload, schedule Schedule) error
+	ProcessUnsentEntries(ctx context.Context, limit int, processor Processor) (int, error)
+	ClaimDueEntries(ctx context.Context, limit int) ([]OutboxEntry, error)
+	ProcessClaimedEntry(ctx context.Context, id int64, leaseExpiresAt time.Time, processor Processor) (bool, error)
+	ReleaseClaimedEntries(ctx context.Context, ids []int64)
+	GetEntryByMessageId(ctx context.Context, messageId string) (*OutboxEntry, error)
+	ListDeadLetters(ctx context.Context, limit, offset int) ([]OutboxEntry, error)
+	GetDeadLetter(ctx context.Context, id int64) (*DeadLetter, error)
+	RequeueDeadLetter(ctx context.Context, id int64) error
+	DiscardDeadLetter(ctx context.Context, id int64) error
+	GetMessageEvents(ctx context.Context, messageId string) ([]Event, error)
+	GetSubscriptionDeliveries(ctx context.Context, outboxId int64) ([]SubscriptionDelivery, error)
+	RecordSubscriptionDelivery(ctx context.Context, outboxId, subscriptionId int64, providerMessageId string, deliveryErr error) error
+	// ApplySettings takes effect for entries claimed from then on.
+	ApplySettings(settings settings.SchedulerSettings)
 }
 
 type service struct {
 	repository Repository
+	config     config.SchedulerConfig
+	settings   atomic.Pointer[settings.SchedulerSettings]
 	logger     *logrus.Logger
 }
 
-func NewService(repository Repository, logger *logrus.Logger) Service {
-	return &service{
+// NewService returns a service using the runtime settings in config until ApplySettings replaces
+// them.
+func NewService(repository Repository, config config.SchedulerConfig, logger *logrus.Logger) Service {
+	s := &service{
 		repository: repository,
+		config:     config,
 		logger:     logger,
 	}
+	initial := settings.FromConfig(config)
+	s.settings.Store(&initial)
+	return s
 }
 
-func (s *service) CreateEntryForMessage(ctx context.Context, tx *sql.Tx, messageId, content, phoneNumber string) error {
+func (s *service) CreateEntryForMessage(ctx context.Context, tx *sql.Tx, payload MessagePayload, schedule Schedule) error {
+	messageId := payload.Id
 	s.logger.WithContext(ctx).Debugf("[outbox.service][CreateEntryForMessage] creating outbox entry for message: %s", messageId)
 
-	payload := MessagePayload{
-		Id:          messageId,
-		Content:     content,
-		PhoneNumber: phoneNumber,
-	}
-
 	payloadBytes, err := json.Marshal(payload)
 	if err != nil {
 		s.logger.WithContext(ctx).WithError(err).Error("failed to marshal outbox payload")
 		return err
 	}
 
+	now := time.Now()
+	nextAttemptAt := now
+	if schedule.SendAt != nil && schedule.SendAt.After(now) {
+		nextAttemptAt = *schedule.SendAt
+	}
+
 	outboxEntry := &OutboxEntry{
-		MessageId: messageId,
-		Payload:   payloadBytes,
-		Sent:      false,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		MessageId:     messageId,
+		EventType:     EventMessageCreated,
+		Payload:       payloadBytes,
+		Status:        StatusPending,
+		NextAttemptAt: nextAttemptAt,
+		ExpiresAt:     schedule.ExpiresAt,
+		CreatedAt:     now,
+		UpdatedAt:     now,
 	}
 
 	err = s.repository.SaveOutboxEntry(ctx, tx, outboxEntry)
@@ -55,16 +88,23 @@ func (s *service) CreateEntryForMessage(ctx context.Context, tx *sql.Tx, message
 		return err
 	}
 
+	// Scheduled entries are left to the regular sweep.
+	if !nextAttemptAt.After(now) {
+		if err := s.repository.NotifyEntryDue(ctx, tx, outboxEntry.Id); err != nil {
+			s.logger.WithContext(ctx).WithError(err).Error("failed to notify outbox entry")
+			return err
+		}
+	}
+
 	s.logger.WithContext(ctx).WithField("message_id", messageId).WithField("outbox_id", outboxEntry.Id).Info("outbox entry created successfully")
 	return nil
 }
 
-func (s *service) ProcessUnsentEntries(ctx context.Context, limit int, processor func(ctx context.Context, entry OutboxEntry) error) (int, error) {
+func (s *service) ProcessUnsentEntries(ctx context.Context, limit int, processor Processor) (int, error) {
 	s.logger.WithContext(ctx).Debugf("[outbox.service][ProcessUnsentEntries] processing unsent entries with limit: %d", limit)
 
-	entries, err := s.repository.GetUnsentEntries(ctx, limit)
+	entries, err := s.ClaimDueEntries(ctx, limit)
 	if err != nil {
-		s.logger.WithContext(ctx).WithError(err).Error("failed to get unsent outbox entries")
 		return 0, err
 	}
 
@@ -75,58 +115,422 @@ func (s *service) ProcessUnsentEntries(ctx context.Context, limit int, processor
 
 	s.logger.WithContext(ctx).Infof("processing %d unsent outbox entries", len(entries))
 
+	outcomes := s.dispatch(ctx, entries, processor)
+
 	var processedCount int
-	var successfulIds []int64
+	var undispatched []int64
+	var markErrors []error
+	for i, outcome := range outcomes {
+		switch {
+		case !outcome.dispatched:
+			undispatched = append(undispatched, entries[i].Id)
+		case outcome.markErr != nil:
+			markErrors = append(markErrors, outcome.markErr)
+		case outcome.delivered:
+			processedCount++
+		}
+	}
 
-	for _, entry := range entries {
+	if len(undispatched) > 0 {
+		s.logger.WithContext(ctx).
+			WithField("undispatched_count", len(undispatched)).
+			Warn("processing cancelled due to context cancellation, releasing remaining entries")
+		s.releaseEntries(ctx, undispatched)
+	}
+
+	s.logger.WithContext(ctx).
+		WithField("processed_count", processedCount).
+		WithField("total_entries", len(entries)).
+		Info("outbox entries processed")
+
+	return processedCount, errors.Join(markErrors...)
+}
+
+// ClaimDueEntries fails entries whose lease expired, expires entries past their delivery window and then
+// leases up to limit due entries to this instance.
+func (s *service) ClaimDueEntries(ctx context.Context, limit int) ([]OutboxEntry, error) {
+	s.logger.WithContext(ctx).Debugf("[outbox.service][ClaimDueEntries] claiming due entries with limit: %d", limit)
+
+	if err := s.expireLeases(ctx); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.repository.ExpireEntries(ctx, sourcesOf(StatusExpired), s.config.InstanceId); err != nil {
+		s.logger.WithContext(ctx).WithError(err).Error("failed to expire stale outbox entries")
+		return nil, err
+	}
+
+	leaseExpiresAt := time.Now().Add(s.leaseDuration())
+	entries, err := s.repository.ClaimEntries(ctx, s.config.InstanceId, sourcesOf(StatusInFlight), limit, leaseExpiresAt)
+	if err != nil {
+		s.logger.WithContext(ctx).WithError(err).Error("failed to claim unsent outbox entries")
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// ProcessClaimedEntry processes an entry claimed by ClaimDueEntries, possibly on another instance,
+// whose lease expires at leaseExpiresAt. The lease is taken over first, so a hand-off that went
+// stale because the lease ran out in the meantime is skipped. It reports whether the entry was
+// delivered; false with a nil error means it was skipped or settled otherwise.
+func (s *service) ProcessClaimedEntry(ctx context.Context, id int64, leaseExpiresAt time.Time, processor Processor) (bool, error) {
+	s.logger.WithContext(ctx).Debugf("[outbox.service][ProcessClaimedEntry] processing claimed entry: %d", id)
+
+	entry, err := s.repository.TakeOverEntry(ctx, id, leaseExpiresAt, s.config.InstanceId, time.Now().Add(s.leaseDuration()))
+	if err != nil {
+		s.logger.WithContext(ctx).WithError(err).WithField("outbox_id", id).Error("failed to take over outbox entry")
+		return false, err
+	}
+
+	if entry == nil {
+		s.logger.WithContext(ctx).WithField("outbox_id", id).Info("outbox entry is no longer claimed under this lease, skipping")
+		return false, nil
+	}
+
+	outcome := s.processEntry(ctx, *entry, processor)
+	return outcome.delivered, outcome.markErr
+}
+
+// entryOutcome is what happened to one claimed entry of a batch.
+type entryOutcome struct {
+	dispatched bool
+	delivered  bool
+	markErr    error
+}
+
+// dispatch runs processor over entries on a bounded pool of workers. Every entry is settled in
+// Postgres as soon as its worker finishes; entries not handed to a worker before ctx is done are
+// reported as not dispatched.
+func (s *service) dispatch(ctx context.Context, entries []OutboxEntry, processor Processor) []entryOutcome {
+	outcomes := make([]entryOutcome, len(entries))
+	jobs := make(chan int)
+
+	workers := s.config.Concurrency
+	if workers <= 0 {
+		workers = 1
+	}
+	if workers > len(entries) {
+		workers = len(entries)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				outcomes[index] = s.processEntry(ctx, entries[index], processor)
+			}
+		}()
+	}
+
+dispatchLoop:
+	for index := range entries {
 		select {
 		case <-ctx.Done():
-			s.logger.WithContext(ctx).Warn("processing cancelled due to context cancellation")
-			break
-		default:
-			if err := processor(ctx, entry); err != nil {
-				s.logger.WithContext(ctx).WithError(err).
-					WithField("outbox_id", entry.Id).
-					WithField("message_id", entry.MessageId).
-					Error("failed to process outbox entry")
-				continue
-			}
+			break dispatchLoop
+		case jobs <- index:
+		}
+	}
+	close(jobs)
+	wg.Wait()
 
-			successfulIds = append(successfulIds, entry.Id)
-			processedCount++
+	return outcomes
+}
+
+func (s *service) processEntry(ctx context.Context, entry OutboxEntry, processor Processor) entryOutcome {
+	outcome := entryOutcome{dispatched: true}
+
+	providerMessageId, err := processor(ctx, entry)
+	if err != nil {
+		if errors.Is(err, ErrDeliveryDeferred) {
+			s.logger.WithContext(ctx).WithError(err).WithField("outbox_id", entry.Id).
+				Info("outbox entry delivery deferred")
+			s.releaseEntries(ctx, []int64{entry.Id})
+			return outcome
 		}
+
+		// An entry cut short by the end of the batch still counts as an attempt: the processor may
+		// already have reached the endpoint, and releasing it would retry it on the next tick with
+		// no backoff. Only entries never handed to a worker go back without one.
+		if ctx.Err() != nil {
+			s.logger.WithContext(ctx).WithError(err).WithField("outbox_id", entry.Id).
+				Warn("outbox entry interrupted by context cancellation")
+			s.recordFailure(ctx, entry, err)
+			return outcome
+		}
+
+		s.logger.WithContext(ctx).WithError(err).
+			WithField("outbox_id", entry.Id).
+			WithField("message_id", entry.MessageId).
+			Error("failed to process outbox entry")
+		s.recordFailure(ctx, entry, err)
+		return outcome
+	}
+
+	// The webhook has accepted the message; marking it must not be skipped because the batch
+	// context expired in the meantime.
+	if err := s.repository.MarkAsDelivered(context.WithoutCancel(ctx), entry.Id, providerMessageId, s.config.InstanceId); err != nil {
+		s.logger.WithContext(ctx).WithError(err).
+			WithField("outbox_id", entry.Id).
+			Error("failed to mark outbox entry as delivered")
+		outcome.markErr = err
+		return outcome
+	}
+
+	outcome.delivered = true
+	return outcome
+}
+
+// ReleaseClaimedEntries hands entries claimed by ClaimDueEntries back to the queue without
+// counting an attempt. Failures are logged.
+func (s *service) ReleaseClaimedEntries(ctx context.Context, ids []int64) {
+	s.logger.WithContext(ctx).Debugf("[outbox.service][ReleaseClaimedEntries] releasing %d claimed entries", len(ids))
+	s.releaseEntries(ctx, ids)
+}
+
+func (s *service) releaseEntries(ctx context.Context, ids []int64) {
+	if err := s.repository.ReleaseEntries(context.WithoutCancel(ctx), ids, s.config.InstanceId); err != nil {
+		s.logger.WithContext(ctx).WithError(err).WithField("ids", ids).Error("failed to release outbox entries")
 	}
+}
+
+func (s *service) GetEntryByMessageId(ctx context.Context, messageId string) (*OutboxEntry, error) {
+	s.logger.WithContext(ctx).Debugf("[outbox.service][GetEntryByMessageId] retrieving outbox entry for message: %s", messageId)
+	return s.repository.GetLatestEntryByMessageId(ctx, messageId)
+}
 
-	if len(successfulIds) > 0 {
-		if err := s.repository.MarkAsSent(ctx, successfulIds); err != nil {
-			s.logger.WithContext(ctx).WithError(err).
-				WithField("successful_ids", successfulIds).
-				Error("failed to mark outbox entries as sent")
-			return processedCount, err
+func (s *service) ListDeadLetters(ctx context.Context, limit, offset int) ([]OutboxEntry, error) {
+	s.logger.WithContext(ctx).Debugf("[outbox.service][ListDeadLetters] listing dead letters with limit: %d, offset: %d", limit, offset)
+	return s.repository.GetEntriesByStatus(ctx, StatusDead, limit, offset)
+}
+
+func (s *service) GetDeadLetter(ctx context.Context, id int64) (*DeadLetter, error) {
+	s.logger.WithContext(ctx).Debugf("[outbox.service][GetDeadLetter] retrieving dead letter: %d", id)
+
+	entry, err := s.getDeadEntry(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrEntryNotFound) {
+			return nil, nil
 		}
+		return nil, err
+	}
 
-		s.logger.WithContext(ctx).
-			WithField("processed_count", processedCount).
-			WithField("total_entries", len(entries)).
-			Info("outbox entries processed successfully")
+	attempts, err := s.repository.GetAttempts(ctx, id)
+	if err != nil {
+		s.logger.WithContext(ctx).WithError(err).WithField("outbox_id", id).Error("failed to get dead letter attempts")
+		return nil, err
 	}
 
-	return processedCount, nil
+	return &DeadLetter{
+		OutboxEntry: *entry,
+		Attempts:    attempts,
+	}, nil
 }
 
-func (s *service) MarkEntriesAsSent(ctx context.Context, ids []int64) error {
-	s.logger.WithContext(ctx).Debugf("[outbox.service][MarkEntriesAsSent] marking %d entries as sent", len(ids))
+func (s *service) RequeueDeadLetter(ctx context.Context, id int64) error {
+	s.logger.WithContext(ctx).Debugf("[outbox.service][RequeueDeadLetter] requeueing dead letter: %d", id)
 
-	if len(ids) == 0 {
-		return nil
+	entry, err := s.getDeadEntry(ctx, id)
+	if err != nil {
+		return err
 	}
 
-	err := s.repository.MarkAsSent(ctx, ids)
+	if err := entry.Status.checkTransition(StatusPending); err != nil {
+		return err
+	}
+
+	if err := s.repository.Requeue(ctx, id, entry.Status, s.config.InstanceId); err != nil {
+		s.logger.WithContext(ctx).WithError(err).WithField("outbox_id", id).Error("failed to requeue dead letter")
+		return err
+	}
+
+	s.logger.WithContext(ctx).WithField("outbox_id", id).Info("dead letter requeued")
+	return nil
+}
+
+func (s *service) DiscardDeadLetter(ctx context.Context, id int64) error {
+	s.logger.WithContext(ctx).Debugf("[outbox.service][DiscardDeadLetter] discarding dead letter: %d", id)
+
+	entry, err := s.getDeadEntry(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := entry.Status.checkTransition(StatusCancelled); err != nil {
+		return err
+	}
+
+	if err := s.repository.Cancel(ctx, id, entry.Status, "discarded from dead letters", s.config.InstanceId); err != nil {
+		s.logger.WithContext(ctx).WithError(err).WithField("outbox_id", id).Error("failed to discard dead letter")
+		return err
+	}
+
+	s.logger.WithContext(ctx).WithField("outbox_id", id).Info("dead letter discarded")
+	return nil
+}
+
+func (s *service) GetMessageEvents(ctx context.Context, messageId string) ([]Event, error) {
+	s.logger.WithContext(ctx).Debugf("[outbox.service][GetMessageEvents] retrieving events for message: %s", messageId)
+	return s.repository.GetEvents(ctx, messageId)
+}
+
+func (s *service) GetSubscriptionDeliveries(ctx context.Context, outboxId int64) ([]SubscriptionDelivery, error) {
+	s.logger.WithContext(ctx).Debugf("[outbox.service][GetSubscriptionDeliveries] retrieving subscription deliveries for entry: %d", outboxId)
+	return s.repository.GetSubscriptionDeliveries(ctx, outboxId)
+}
+
+// RecordSubscriptionDelivery stores the outcome of sending an entry to one subscription. A nil
+// deliveryErr marks that subscription as done and a permanent DeliveryError as rejected; retries
+// of the entry skip both.
+func (s *service) RecordSubscriptionDelivery(ctx context.Context, outboxId, subscriptionId int64, providerMessageId string, deliveryErr error) error {
+	s.logger.WithContext(ctx).Debugf("[outbox.service][RecordSubscriptionDelivery] recording delivery of entry: %d to subscription: %d", outboxId, subscriptionId)
+
+	status := DeliveryDelivered
+	var lastError *string
+	if deliveryErr != nil {
+		status = DeliveryFailed
+		var classified *DeliveryError
+		if errors.As(deliveryErr, &classified) && classified.Class == FailurePermanent {
+			status = DeliveryRejected
+		}
+
+		message := deliveryErr.Error()
+		lastError = &message
+	}
+
+	// Like the entry itself, the outcome of a call that already happened must be kept even when
+	// the batch context ran out meanwhile.
+	err := s.repository.SaveSubscriptionDelivery(context.WithoutCancel(ctx), outboxId, subscriptionId, status, providerMessageId, lastError)
+	if err != nil {
+		s.logger.WithContext(ctx).WithError(err).
+			WithField("outbox_id", outboxId).
+			WithField("subscription_id", subscriptionId).
+			Error("failed to record subscription delivery")
+		return err
+	}
+
+	return nil
+}
+
+func (s *service) getDeadEntry(ctx context.Context, id int64) (*OutboxEntry, error) {
+	entry, err := s.repository.GetEntry(ctx, id)
 	if err != nil {
-		s.logger.WithContext(ctx).WithError(err).WithField("ids", ids).Error("failed to mark entries as sent")
+		s.logger.WithContext(ctx).WithError(err).WithField("outbox_id", id).Error("failed to get outbox entry")
+		return nil, err
+	}
+
+	if entry == nil || entry.Status != StatusDead {
+		return nil, ErrEntryNotFound
+	}
+
+	return entry, nil
+}
+
+func (s *service) ApplySettings(newSettings settings.SchedulerSettings) {
+	s.logger.Debugf("[outbox.service][ApplySettings] settings: %+v", newSettings)
+	s.settings.Store(&newSettings)
+}
+
+// leaseDuration is how long claimed entries stay reserved for this instance. It has to outlive a
+// whole batch, so it falls back to the send timeout when not configured.
+func (s *service) leaseDuration() time.Duration {
+	if s.config.LeaseDuration > 0 {
+		return s.config.LeaseDuration
+	}
+	return s.settings.Load().SendTimeout
+}
+
+// expireLeases counts an expired lease as a failed attempt, since the worker that held it may have
+// reached the endpoint before it died, and schedules the entry for retry with backoff or moves it
+// to dead once its attempts are exhausted.
+func (s *service) expireLeases(ctx context.Context) error {
+	now := time.Now()
+	entries, err := s.repository.FindExpiredLeases(ctx, now)
+	if err != nil {
+		s.logger.WithContext(ctx).WithError(err).Error("failed to find expired outbox leases")
 		return err
 	}
 
-	s.logger.WithContext(ctx).WithField("count", len(ids)).Info("entries marked as sent successfully")
+	for _, entry := range entries {
+		attempt := entry.AttemptCount + 1
+		next := StatusRetrying
+		if s.config.MaxAttempts > 0 && attempt >= s.config.MaxAttempts {
+			next = StatusDead
+		}
+		nextAttemptAt := time.Now().Add(nextAttemptDelay(s.config.Backoff, attempt))
+
+		expired, err := s.repository.ExpireLease(ctx, entry.Id, next, nextAttemptAt, now, s.config.InstanceId)
+		if err != nil {
+			s.logger.WithContext(ctx).WithError(err).WithField("outbox_id", entry.Id).Error("failed to expire outbox lease")
+			return err
+		}
+
+		if expired {
+			s.logger.WithContext(ctx).
+				WithField("outbox_id", entry.Id).
+				WithField("attempt", attempt).
+				WithField("status", next).
+				Warn("outbox entry lease expired, attempt counted")
+		}
+	}
+
 	return nil
 }
+
+// recordFailure moves a failed entry to retrying, or to dead once its attempts are exhausted or
+// the processor classified the failure as permanent. A Retry-After longer than the backoff
+// postpones the next attempt accordingly.
+func (s *service) recordFailure(ctx context.Context, entry OutboxEntry, processErr error) {
+	var deliveryErr *DeliveryError
+	errors.As(processErr, &deliveryErr)
+
+	attempt := entry.AttemptCount + 1
+	next := StatusRetrying
+	if s.config.MaxAttempts > 0 && attempt >= s.config.MaxAttempts {
+		next = StatusDead
+	}
+	if deliveryErr != nil && deliveryErr.Class == FailurePermanent {
+		next = StatusDead
+	}
+
+	if err := entry.Status.checkTransition(next); err != nil {
+		s.logger.WithContext(ctx).WithError(err).WithField("outbox_id", entry.Id).Error("cannot record outbox entry failure")
+		return
+	}
+
+	delay := nextAttemptDelay(s.config.Backoff, attempt)
+	if deliveryErr != nil && deliveryErr.RetryAfter > delay {
+		delay = deliveryErr.RetryAfter
+	}
+	nextAttemptAt := time.Now().Add(delay)
+
+	// The batch context may already be past its deadline; the failure still has to be recorded
+	// or the entry would be picked up again on the next tick without any backoff.
+	err := s.repository.MarkAsFailed(context.WithoutCancel(ctx), entry.Id, next, processErr.Error(), nextAttemptAt, s.config.InstanceId)
+	if err != nil {
+		s.logger.WithContext(ctx).WithError(err).
+			WithField("outbox_id", entry.Id).
+			Error("failed to record outbox entry failure")
+		return
+	}
+
+	if next == StatusDead {
+		message := "outbox entry exhausted its retries and moved to dead letters"
+		if deliveryErr != nil && deliveryErr.Class == FailurePermanent {
+			message = "outbox entry failed permanently and moved to dead letters"
+		}
+		s.logger.WithContext(ctx).
+			WithField("outbox_id", entry.Id).
+			WithField("attempt", attempt).
+			Error(message)
+		return
+	}
+
+	s.logger.WithContext(ctx).
+		WithField("outbox_id", entry.Id).
+		WithField("attempt", attempt).
+		WithField("next_attempt_at", nextAttemptAt).
+		Warn("outbox entry scheduled for retry")
+}
@@ -0,0 +1,70 @@
+package outbox
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrInvalidTransition = errors.New("invalid outbox status transition")
+	// ErrDeliveryDeferred marks processor errors that are not the entry's fault, such as an open
+	// circuit breaker. Such entries are put back in the queue without consuming an attempt.
+	ErrDeliveryDeferred = errors.New("outbox delivery deferred")
+)
+
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusInFlight  Status = "in_flight"
+	StatusDelivered Status = "delivered"
+	StatusRetrying  Status = "retrying"
+	StatusDead      Status = "dead"
+	StatusCancelled Status = "cancelled"
+	StatusExpired   Status = "expired"
+)
+
+// transitions lists, for every status, the statuses an entry may move to next. Delivered,
+// cancelled and expired are terminal.
+var transitions = map[Status][]Status{
+	StatusPending:   {StatusInFlight, StatusCancelled, StatusExpired},
+	StatusInFlight:  {StatusDelivered, StatusRetrying, StatusDead, StatusPending},
+	StatusRetrying:  {StatusInFlight, StatusCancelled, StatusExpired},
+	StatusDead:      {StatusPending, StatusCancelled},
+	StatusDelivered: {},
+	StatusCancelled: {},
+	StatusExpired:   {},
+}
+
+func (s Status) CanTransitionTo(next Status) bool {
+	for _, allowed := range transitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTransition reports an ErrInvalidTransition when the state machine does not allow moving
+// from s to next.
+func (s Status) checkTransition(next Status) error {
+	if !s.CanTransitionTo(next) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, s, next)
+	}
+	return nil
+}
+
+func (s Status) IsTerminal() bool {
+	return len(transitions[s]) == 0
+}
+
+// sourcesOf returns every status that is allowed to move to target.
+func sourcesOf(target Status) []Status {
+	sources := make([]Status, 0)
+	for from := range transitions {
+		if from.CanTransitionTo(target) {
+			sources = append(sources, from)
+		}
+	}
+	return sources
+}
@@ -0,0 +1,230 @@
+package payload
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/serhatYilmazz/message-sender/internal/config"
+	"strconv"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const (
+	// FormatDefault is the original body: id, content, phoneNumber and timestamp.
+	FormatDefault = "default"
+	// FormatTemplate renders a text/template that has to produce JSON.
+	FormatTemplate = "template"
+	// FormatCloudEvents is a CloudEvents 1.0 event in structured content mode.
+	FormatCloudEvents = "cloudevents"
+	// FormatCloudEventsBinary is a CloudEvents 1.0 event in binary content mode: the attributes
+	// travel as ce-* headers and the body is the event data alone.
+	FormatCloudEventsBinary = "cloudevents_binary"
+)
+
+const (
+	contentTypeJSON       = "application/json"
+	contentTypeCloudEvent = "application/cloudevents+json"
+	cloudEventsVersion    = "1.0"
+	defaultSource         = "/message-sender"
+)
+
+var (
+	ErrUnknownFormat   = errors.New("unknown webhook payload format")
+	ErrInvalidTemplate = errors.New("invalid webhook payload template")
+)
+
+var templateFuncs = template.FuncMap{
+	// json encodes a value as a JSON literal, so templates do not have to quote or escape strings.
+	"json": func(value any) (string, error) {
+		encoded, err := json.Marshal(value)
+		return string(encoded), err
+	},
+}
+
+// Message is the data a webhook body is rendered from. Its fields are what templates can refer to.
+type Message struct {
+	OutboxId    int64
+	EventType   string
+	Id          string
+	Content     string
+	PhoneNumber string
+	Tags        []string
+	CreatedAt   time.Time
+}
+
+// Body is a rendered webhook request body together with the headers its format requires.
+type Body struct {
+	ContentType string
+	Headers     map[string]string
+	Data        []byte
+}
+
+type messageData struct {
+	Id          string   `json:"id"`
+	Content     string   `json:"content"`
+	PhoneNumber string   `json:"phoneNumber"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+type cloudEvent struct {
+	SpecVersion     string      `json:"specversion"`
+	Id              string      `json:"id"`
+	Source          string      `json:"source"`
+	Type            string      `json:"type"`
+	Subject         string      `json:"subject"`
+	Time            string      `json:"time"`
+	DataContentType string      `json:"datacontenttype"`
+	Data            messageData `json:"data"`
+}
+
+// Renderer turns messages into webhook bodies. Parsed templates are cached by their text since
+// every subscription may carry its own.
+type Renderer struct {
+	config    config.PayloadConfig
+	templates sync.Map
+}
+
+// NewRenderer fails when the configured default format or template is invalid.
+func NewRenderer(config config.PayloadConfig) (*Renderer, error) {
+	if err := Validate(config.Format, config.Template); err != nil {
+		return nil, err
+	}
+
+	return &Renderer{config: config}, nil
+}
+
+// Validate checks a format and, for FormatTemplate, that the template parses. An empty format is
+// valid and stands for the configured default.
+func Validate(format, text string) error {
+	switch format {
+	case "", FormatDefault, FormatCloudEvents, FormatCloudEventsBinary:
+		return nil
+	case FormatTemplate:
+		if text == "" {
+			return fmt.Errorf("%w: template is empty", ErrInvalidTemplate)
+		}
+		_, err := parse(text)
+		return err
+	}
+
+	return fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+}
+
+// Render builds the body for message. An empty format falls back to the configured one together
+// with the configured template.
+func (r *Renderer) Render(format, text string, message Message) (*Body, error) {
+	if format == "" {
+		format, text = r.config.Format, r.config.Template
+	}
+
+	data := messageData{
+		Id:          message.Id,
+		Content:     message.Content,
+		PhoneNumber: message.PhoneNumber,
+		Tags:        message.Tags,
+	}
+	timestamp := message.CreatedAt.Format(time.RFC3339)
+
+	switch format {
+	case "", FormatDefault:
+		body, err := json.Marshal(map[string]interface{}{
+			"id":          data.Id,
+			"content":     data.Content,
+			"phoneNumber": data.PhoneNumber,
+			"timestamp":   timestamp,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &Body{ContentType: contentTypeJSON, Data: body}, nil
+
+	case FormatTemplate:
+		body, err := r.renderTemplate(text, message)
+		if err != nil {
+			return nil, err
+		}
+		return &Body{ContentType: contentTypeJSON, Data: body}, nil
+
+	case FormatCloudEvents:
+		body, err := json.Marshal(cloudEvent{
+			SpecVersion:     cloudEventsVersion,
+			Id:              eventId(message),
+			Source:          r.source(),
+			Type:            message.EventType,
+			Subject:         message.Id,
+			Time:            timestamp,
+			DataContentType: contentTypeJSON,
+			Data:            data,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &Body{ContentType: contentTypeCloudEvent, Data: body}, nil
+
+	case FormatCloudEventsBinary:
+		body, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		return &Body{
+			ContentType: contentTypeJSON,
+			Headers: map[string]string{
+				"ce-specversion": cloudEventsVersion,
+				"ce-id":          eventId(message),
+				"ce-source":      r.source(),
+				"ce-type":        message.EventType,
+				"ce-subject":     message.Id,
+				"ce-time":        timestamp,
+			},
+			Data: body,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+}
+
+func (r *Renderer) renderTemplate(text string, message Message) ([]byte, error) {
+	cached, ok := r.templates.Load(text)
+	if !ok {
+		parsed, err := parse(text)
+		if err != nil {
+			return nil, err
+		}
+		cached, _ = r.templates.LoadOrStore(text, parsed)
+	}
+
+	var body bytes.Buffer
+	if err := cached.(*template.Template).Execute(&body, message); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+	}
+
+	if !json.Valid(body.Bytes()) {
+		return nil, fmt.Errorf("%w: rendered body is not valid JSON", ErrInvalidTemplate)
+	}
+
+	return body.Bytes(), nil
+}
+
+func (r *Renderer) source() string {
+	if r.config.CloudEventsSource != "" {
+		return r.config.CloudEventsSource
+	}
+	return defaultSource
+}
+
+func parse(text string) (*template.Template, error) {
+	parsed, err := template.New("payload").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+	}
+	return parsed, nil
+}
+
+// eventId is the same for every delivery attempt of an entry, so receivers can deduplicate
+// CloudEvents by source and id.
+func eventId(message Message) string {
+	return strconv.FormatInt(message.OutboxId, 10)
+}
@@ -2,30 +2,61 @@ package scheduler
 
 import (
 	"context"
+	"github.com/serhatYilmazz/message-sender/internal/settings"
+	"github.com/serhatYilmazz/message-sender/internal/webhook"
 	"github.com/serhatYilmazz/message-sender/pkg/model"
 	"github.com/sirupsen/logrus"
+	"strconv"
 )
 
 type ControlService interface {
+	// ProcessMessageSender stores the enabled flag for every instance and applies it here right
+	// away; the others follow from the change notification.
 	ProcessMessageSender(ctx context.Context, request model.MessageSenderRequest) error
-	GetSchedulerStatus(ctx context.Context) bool
+	PauseMessageSender(ctx context.Context) error
+	ResumeMessageSender(ctx context.Context) error
+	RestartMessageSender(ctx context.Context) error
+	GetSchedulerStatus(ctx context.Context) model.SchedulerStatus
+	GetSchedulerConfig(ctx context.Context) (*model.SchedulerConfigDto, error)
+	// UpdateSchedulerConfig stores the new settings for every instance and applies them here
+	// right away; the others pick them up from the change notification.
+	UpdateSchedulerConfig(ctx context.Context, request model.SchedulerConfigRequest) (*model.SchedulerConfigDto, error)
 }
 
 type controlService struct {
-	manager Manager
-	logger  *logrus.Logger
+	manager         Manager
+	breaker         webhook.CircuitBreaker
+	settingsService settings.Service
+	election        *LeaderElection
+	instanceId      string
+	logger          *logrus.Logger
 }
 
-func NewControlService(manager Manager, logger *logrus.Logger) ControlService {
+func NewControlService(
+	manager Manager,
+	breaker webhook.CircuitBreaker,
+	settingsService settings.Service,
+	election *LeaderElection,
+	instanceId string,
+	logger *logrus.Logger,
+) ControlService {
 	return &controlService{
-		manager: manager,
-		logger:  logger,
+		manager:         manager,
+		breaker:         breaker,
+		settingsService: settingsService,
+		election:        election,
+		instanceId:      instanceId,
+		logger:          logger,
 	}
 }
 
 func (c *controlService) ProcessMessageSender(ctx context.Context, request model.MessageSenderRequest) error {
 	c.logger.WithContext(ctx).Debugf("[scheduler.control][ProcessMessageSender] request: %+v", request)
 
+	if _, err := c.settingsService.SetEnabled(ctx, request.IsMessageSenderEnabled); err != nil {
+		return err
+	}
+
 	switch request.IsMessageSenderEnabled {
 	case true:
 		c.logger.WithContext(ctx).Info("[scheduler.control][ProcessMessageSender] enabling message sender")
@@ -38,7 +69,108 @@ func (c *controlService) ProcessMessageSender(ctx context.Context, request model
 	return nil
 }
 
-func (c *controlService) GetSchedulerStatus(ctx context.Context) bool {
+func (c *controlService) PauseMessageSender(ctx context.Context) error {
+	c.logger.WithContext(ctx).Debug("[scheduler.control][PauseMessageSender] is called")
+	return c.manager.PauseScheduler()
+}
+
+func (c *controlService) ResumeMessageSender(ctx context.Context) error {
+	c.logger.WithContext(ctx).Debug("[scheduler.control][ResumeMessageSender] is called")
+	return c.manager.ResumeScheduler()
+}
+
+func (c *controlService) RestartMessageSender(ctx context.Context) error {
+	c.logger.WithContext(ctx).Debug("[scheduler.control][RestartMessageSender] is called")
+	return c.manager.RestartScheduler(ctx)
+}
+
+func (c *controlService) GetSchedulerStatus(ctx context.Context) model.SchedulerStatus {
 	c.logger.WithContext(ctx).Debug("[scheduler.control][GetSchedulerStatus] checking scheduler status")
-	return c.manager.IsSchedulerRunning()
+
+	status := model.SchedulerStatus{
+		IsRunning:  c.manager.IsSchedulerRunning(),
+		State:      string(c.manager.State()),
+		InstanceId: c.instanceId,
+	}
+
+	// The status of this instance is still worth reporting when Postgres is unreachable.
+	if current, err := c.settingsService.GetSettings(ctx); err != nil {
+		c.logger.WithContext(ctx).WithError(err).Warn("failed to read the cluster-wide message sender state")
+	} else {
+		status.DesiredEnabled = &current.Enabled
+	}
+
+	if instances, err := c.settingsService.ListInstanceStates(ctx); err != nil {
+		c.logger.WithContext(ctx).WithError(err).Warn("failed to list scheduler instance states")
+	} else {
+		for _, instance := range instances {
+			status.Instances = append(status.Instances, model.SchedulerInstanceStatus{
+				InstanceId:  instance.InstanceId,
+				State:       instance.State,
+				HeartbeatAt: instance.HeartbeatAt,
+			})
+		}
+	}
+
+	if c.election != nil {
+		status.Leader = c.leaderStatus(ctx)
+	}
+
+	if c.breaker != nil {
+		status.CircuitBreakers = make(map[string]string)
+		for subscriptionId, state := range c.breaker.States() {
+			status.CircuitBreakers[strconv.FormatInt(subscriptionId, 10)] = string(state)
+		}
+	}
+
+	return status
+}
+
+func (c *controlService) leaderStatus(ctx context.Context) *model.SchedulerLeaderStatus {
+	leader := &model.SchedulerLeaderStatus{IsThisInstance: c.election.IsLeader()}
+
+	instanceId, leaseExpiresAt, err := c.election.Leader(ctx)
+	if err != nil {
+		c.logger.WithContext(ctx).WithError(err).Warn("failed to read the scheduler leader")
+		return leader
+	}
+
+	if instanceId != "" {
+		leader.InstanceId = instanceId
+		leader.LeaseExpiresAt = &leaseExpiresAt
+	}
+	return leader
+}
+
+func (c *controlService) GetSchedulerConfig(ctx context.Context) (*model.SchedulerConfigDto, error) {
+	c.logger.WithContext(ctx).Debug("[scheduler.control][GetSchedulerConfig] is called")
+
+	current, err := c.settingsService.GetSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return toSchedulerConfigDto(*current), nil
+}
+
+func (c *controlService) UpdateSchedulerConfig(ctx context.Context, request model.SchedulerConfigRequest) (*model.SchedulerConfigDto, error) {
+	c.logger.WithContext(ctx).Debugf("[scheduler.control][UpdateSchedulerConfig] request: %+v", request)
+
+	updated, err := c.settingsService.UpdateSettings(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	c.manager.ApplySettings(*updated)
+	return toSchedulerConfigDto(*updated), nil
+}
+
+func toSchedulerConfigDto(current settings.SchedulerSettings) *model.SchedulerConfigDto {
+	return &model.SchedulerConfigDto{
+		Interval:     current.Interval.String(),
+		BatchSize:    current.BatchSize,
+		SendTimeout:  current.SendTimeout.String(),
+		DrainTimeout: current.DrainTimeout.String(),
+		UpdatedAt:    current.UpdatedAt,
+	}
 }
@@ -0,0 +1,135 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/serhatYilmazz/message-sender/internal/settings"
+	"github.com/serhatYilmazz/message-sender/pkg/model"
+)
+
+// stubSettingsService keeps the settings in memory instead of Postgres.
+type stubSettingsService struct {
+	mu       sync.Mutex
+	settings settings.SchedulerSettings
+}
+
+func (s *stubSettingsService) GetSettings(context.Context) (*settings.SchedulerSettings, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current := s.settings
+	return &current, nil
+}
+
+func (s *stubSettingsService) EnsureSettings(ctx context.Context) (*settings.SchedulerSettings, error) {
+	return s.GetSettings(ctx)
+}
+
+func (s *stubSettingsService) UpdateSettings(ctx context.Context, _ model.SchedulerConfigRequest) (*settings.SchedulerSettings, error) {
+	return s.GetSettings(ctx)
+}
+
+func (s *stubSettingsService) SetEnabled(_ context.Context, enabled bool) (*settings.SchedulerSettings, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.settings.Enabled = enabled
+	current := s.settings
+	return &current, nil
+}
+
+func (s *stubSettingsService) ReportInstanceState(context.Context, string) error {
+	return nil
+}
+
+func (s *stubSettingsService) ListInstanceStates(context.Context) ([]settings.InstanceState, error) {
+	return nil, nil
+}
+
+// requestContext mimics a request handled by fiber: the context is done as soon as the handler
+// returns.
+func requestContext(call func(ctx context.Context) error) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	return call(ctx)
+}
+
+func TestControlServiceConcurrentToggling(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+
+	fake := &fakeScheduler{batchDuration: time.Millisecond}
+	m := newTestManager(fake, 50*time.Millisecond)
+	control := NewControlService(m, nil, &stubSettingsService{}, nil, "test-instance", newTestLogger())
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < 16; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				err := requestContext(func(ctx context.Context) error {
+					switch (worker + i) % 6 {
+					case 0, 1:
+						return control.ProcessMessageSender(ctx, model.MessageSenderRequest{IsMessageSenderEnabled: true})
+					case 2:
+						return control.ProcessMessageSender(ctx, model.MessageSenderRequest{IsMessageSenderEnabled: false})
+					case 3:
+						return control.RestartMessageSender(ctx)
+					case 4:
+						return control.PauseMessageSender(ctx)
+					default:
+						_ = control.GetSchedulerStatus(ctx)
+						return control.ResumeMessageSender(ctx)
+					}
+				})
+				if err != nil && !errors.Is(err, ErrSchedulerNotRunning) {
+					t.Errorf("unexpected error: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	err := requestContext(func(ctx context.Context) error {
+		return control.ProcessMessageSender(ctx, model.MessageSenderRequest{IsMessageSenderEnabled: true})
+	})
+	if err != nil {
+		t.Fatalf("enable: %v", err)
+	}
+
+	// The run must not end with the request that started it.
+	time.Sleep(20 * time.Millisecond)
+	status := control.GetSchedulerStatus(context.Background())
+	if status.State != string(StateRunning) && status.State != string(StatePaused) {
+		t.Fatalf("state after enable %s, want running or paused", status.State)
+	}
+	if status.DesiredEnabled == nil || !*status.DesiredEnabled {
+		t.Fatal("enabled flag was not stored")
+	}
+
+	err = requestContext(func(ctx context.Context) error {
+		return control.ProcessMessageSender(ctx, model.MessageSenderRequest{IsMessageSenderEnabled: false})
+	})
+	if err != nil {
+		t.Fatalf("disable: %v", err)
+	}
+
+	status = control.GetSchedulerStatus(context.Background())
+	if status.State != string(StateStopped) || status.IsRunning {
+		t.Fatalf("state after disable %s, want %s", status.State, StateStopped)
+	}
+	if status.DesiredEnabled == nil || *status.DesiredEnabled {
+		t.Fatal("disabled flag was not stored")
+	}
+
+	if maxActive := fake.maxActive.Load(); maxActive > 1 {
+		t.Fatalf("%d scheduler runs overlapped", maxActive)
+	}
+	if active := fake.active.Load(); active != 0 {
+		t.Fatalf("%d scheduler runs still active after disable", active)
+	}
+	waitForGoroutines(t, baseline)
+}
@@ -0,0 +1,118 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"github.com/serhatYilmazz/message-sender/internal/config"
+	redisClient "github.com/serhatYilmazz/message-sender/pkg/redis"
+	"github.com/sirupsen/logrus"
+	"sync/atomic"
+	"time"
+)
+
+// LeaderElection lets a single instance claim outbox entries at a time. An instance only competes
+// for the lease while its scheduler runs, and counts as leader until the lease it last acquired
+// would expire, so a leader cut off from Redis steps down before another instance can take over.
+type LeaderElection struct {
+	lease         *redisClient.Lease
+	instanceId    string
+	leaseDuration time.Duration
+	renewInterval time.Duration
+	validUntil    atomic.Int64
+	logger        *logrus.Logger
+}
+
+// NewLeaderElection returns the election used when scheduler.leader_election is enabled, or nil.
+func NewLeaderElection(client *redisClient.Client, schedulerConfig config.SchedulerConfig, logger *logrus.Logger) (*LeaderElection, error) {
+	electionConfig := schedulerConfig.LeaderElection
+	if !electionConfig.Enabled {
+		return nil, nil
+	}
+
+	if electionConfig.RenewInterval <= 0 || electionConfig.RenewInterval >= electionConfig.LeaseDuration {
+		return nil, errors.New("scheduler.leader_election.renew_interval must be positive and shorter than lease_duration")
+	}
+
+	return &LeaderElection{
+		lease:         client.Lease(electionConfig.Key, electionConfig.LeaseDuration),
+		instanceId:    schedulerConfig.InstanceId,
+		leaseDuration: electionConfig.LeaseDuration,
+		renewInterval: electionConfig.RenewInterval,
+		logger:        logger,
+	}, nil
+}
+
+func (e *LeaderElection) IsLeader() bool {
+	return time.Now().UnixNano() < e.validUntil.Load()
+}
+
+// Leader returns the instance holding the lease and when the lease expires unless renewed, or an
+// empty instance ID while there is no leader.
+func (e *LeaderElection) Leader(ctx context.Context) (string, time.Time, error) {
+	return e.lease.Holder(ctx)
+}
+
+// run competes for the lease until ctx is done, calling promoted whenever this instance becomes
+// the leader, and gives the lease up on the way out so a follower does not have to wait for it
+// to expire.
+func (e *LeaderElection) run(ctx context.Context, promoted func()) {
+	ticker := time.NewTicker(e.renewInterval)
+	defer ticker.Stop()
+
+	for {
+		e.renew(ctx, promoted)
+
+		select {
+		case <-ctx.Done():
+			e.release(ctx)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *LeaderElection) renew(ctx context.Context, promoted func()) {
+	wasLeader := e.IsLeader()
+
+	// The lease is counted from before the request, so this instance never believes it holds the
+	// lease longer than Redis does.
+	attemptedAt := time.Now()
+	held, err := e.lease.Acquire(ctx, e.instanceId)
+	if err != nil {
+		if ctx.Err() == nil {
+			e.logger.WithContext(ctx).WithError(err).Warn("failed to renew scheduler leadership")
+		}
+		return
+	}
+
+	if !held {
+		if wasLeader {
+			e.logger.WithContext(ctx).Warn("lost scheduler leadership to another instance")
+		}
+		e.validUntil.Store(0)
+		return
+	}
+
+	e.validUntil.Store(attemptedAt.Add(e.leaseDuration).UnixNano())
+	if !wasLeader {
+		e.logger.WithContext(ctx).WithField("instance_id", e.instanceId).Info("became the scheduler leader")
+		promoted()
+	}
+}
+
+func (e *LeaderElection) release(ctx context.Context) {
+	wasLeader := e.IsLeader()
+	e.validUntil.Store(0)
+	if !wasLeader {
+		return
+	}
+
+	releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	if err := e.lease.Release(releaseCtx, e.instanceId); err != nil {
+		e.logger.WithContext(ctx).WithError(err).Warn("failed to release scheduler leadership")
+		return
+	}
+	e.logger.WithContext(ctx).WithField("instance_id", e.instanceId).Info("released scheduler leadership")
+}
@@ -3,28 +3,57 @@ package scheduler
 import (
 	"context"
 	"errors"
+	"github.com/serhatYilmazz/message-sender/internal/settings"
 	"github.com/sirupsen/logrus"
 	"sync"
+	"time"
 )
 
+// State is the lifecycle state of the scheduler on this instance.
+type State string
+
+const (
+	StateStopped  State = "stopped"
+	StateRunning  State = "running"
+	StatePaused   State = "paused"
+	StateStopping State = "stopping"
+)
+
+var ErrSchedulerNotRunning = errors.New("scheduler is not running")
+
 type Manager interface {
 	StartScheduler(ctx context.Context) error
+	// StopScheduler waits for the batch in flight to finish, aborting it after the drain timeout.
 	StopScheduler() error
+	RestartScheduler(ctx context.Context) error
+	PauseScheduler() error
+	ResumeScheduler() error
+	ApplySettings(settings settings.SchedulerSettings)
+	State() State
 	IsSchedulerRunning() bool
 }
 
+// manager runs the scheduler loop in its own goroutine. Every run gets its own contexts and done
+// channel, so the scheduler can be stopped and started again any number of times.
 type manager struct {
-	scheduler Scheduler
-	logger    *logrus.Logger
-	ctx       context.Context
-	cancel    context.CancelFunc
-	mu        sync.RWMutex
+	scheduler    Scheduler
+	drainTimeout time.Duration
+	logger       *logrus.Logger
+	mu           sync.Mutex
+	state        State
+	stop         context.CancelFunc
+	abort        context.CancelFunc
+	done         chan struct{}
 }
 
-func NewManager(scheduler Scheduler, logger *logrus.Logger) Manager {
+// NewManager returns a manager for scheduler. A drainTimeout of zero lets StopScheduler wait for
+// the batch in flight however long it takes, which SendTimeout still bounds.
+func NewManager(scheduler Scheduler, drainTimeout time.Duration, logger *logrus.Logger) Manager {
 	return &manager{
-		scheduler: scheduler,
-		logger:    logger,
+		scheduler:    scheduler,
+		drainTimeout: drainTimeout,
+		logger:       logger,
+		state:        StateStopped,
 	}
 }
 
@@ -32,51 +61,150 @@ func (m *manager) StartScheduler(ctx context.Context) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if m.scheduler.IsRunning() {
-		m.logger.Info("[scheduler.manager][StartScheduler] scheduler is already running")
+	// A start right after a stop waits for the previous run to finish instead of failing.
+	for m.state == StateStopping {
+		done := m.done
+		m.mu.Unlock()
+		<-done
+		m.mu.Lock()
+	}
+
+	if m.state != StateStopped {
+		m.logger.WithContext(ctx).Info("[scheduler.manager][StartScheduler] scheduler is already running")
 		return nil
 	}
 
-	m.logger.Info("[scheduler.manager][StartScheduler] starting scheduler")
+	m.logger.WithContext(ctx).Info("[scheduler.manager][StartScheduler] starting scheduler")
 
-	m.ctx, m.cancel = context.WithCancel(ctx)
+	// The run outlives the request that started it, and on the HTTP path ctx is a pooled fasthttp
+	// request context that is reused once the request is done, so nothing of it is kept.
+	runCtx, stop := context.WithCancel(context.Background())
+	workCtx, abort := context.WithCancel(context.Background())
+	done := make(chan struct{})
 
-	go func() {
-		if err := m.scheduler.Start(m.ctx); err != nil && !errors.Is(err, context.Canceled) {
-			m.logger.WithError(err).Error("[scheduler.manager] scheduler stopped with error")
-		}
-	}()
+	m.scheduler.Resume()
+	m.state = StateRunning
+	m.stop = stop
+	m.abort = abort
+	m.done = done
 
-	m.logger.Info("[scheduler.manager][StartScheduler] scheduler started successfully")
+	go m.run(runCtx, workCtx, done)
+
+	m.logger.WithContext(ctx).Info("[scheduler.manager][StartScheduler] scheduler started successfully")
 	return nil
 }
 
-func (m *manager) StopScheduler() error {
+func (m *manager) run(ctx context.Context, workCtx context.Context, done chan struct{}) {
+	if err := m.scheduler.Run(ctx, workCtx); err != nil && !errors.Is(err, context.Canceled) {
+		m.logger.WithError(err).Error("[scheduler.manager] scheduler stopped with error")
+	}
+
 	m.mu.Lock()
-	defer m.mu.Unlock()
+	m.stop()
+	m.abort()
+	m.state = StateStopped
+	m.stop = nil
+	m.abort = nil
+	m.done = nil
+	m.mu.Unlock()
+
+	close(done)
+}
 
-	if !m.scheduler.IsRunning() {
+func (m *manager) StopScheduler() error {
+	m.mu.Lock()
+	if m.state == StateStopped {
+		m.mu.Unlock()
 		m.logger.Info("[scheduler.manager][StopScheduler] scheduler is not running")
 		return nil
 	}
 
-	m.logger.Info("[scheduler.manager][StopScheduler] stopping scheduler")
-
-	if m.cancel != nil {
-		m.cancel()
+	if m.state != StateStopping {
+		m.logger.Info("[scheduler.manager][StopScheduler] stopping scheduler")
+		m.state = StateStopping
+		m.stop()
+	}
+	done := m.done
+	abort := m.abort
+	drainTimeout := m.drainTimeout
+	m.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if drainTimeout > 0 {
+		timer := time.NewTimer(drainTimeout)
+		defer timer.Stop()
+		timeout = timer.C
 	}
 
-	if err := m.scheduler.Stop(); err != nil {
-		m.logger.WithError(err).Error("[scheduler.manager][StopScheduler] error stopping scheduler")
-		return err
+	select {
+	case <-done:
+	case <-timeout:
+		m.logger.WithField("drain_timeout", drainTimeout).
+			Warn("[scheduler.manager][StopScheduler] batch in flight did not finish in time, aborting it")
+		abort()
+		<-done
 	}
 
 	m.logger.Info("[scheduler.manager][StopScheduler] scheduler stopped successfully")
 	return nil
 }
 
+func (m *manager) RestartScheduler(ctx context.Context) error {
+	m.logger.WithContext(ctx).Info("[scheduler.manager][RestartScheduler] restarting scheduler")
+
+	if err := m.StopScheduler(); err != nil {
+		return err
+	}
+	return m.StartScheduler(ctx)
+}
+
+func (m *manager) PauseScheduler() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch m.state {
+	case StatePaused:
+		return nil
+	case StateRunning:
+		m.logger.Info("[scheduler.manager][PauseScheduler] pausing scheduler")
+		m.scheduler.Pause()
+		m.state = StatePaused
+		return nil
+	}
+	return ErrSchedulerNotRunning
+}
+
+func (m *manager) ResumeScheduler() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch m.state {
+	case StateRunning:
+		return nil
+	case StatePaused:
+		m.logger.Info("[scheduler.manager][ResumeScheduler] resuming scheduler")
+		m.scheduler.Resume()
+		m.state = StateRunning
+		return nil
+	}
+	return ErrSchedulerNotRunning
+}
+
+// ApplySettings hands new runtime settings to the scheduler, whether it is running or not.
+func (m *manager) ApplySettings(newSettings settings.SchedulerSettings) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.drainTimeout = newSettings.DrainTimeout
+	m.scheduler.ApplySettings(newSettings)
+}
+
+func (m *manager) State() State {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state
+}
+
 func (m *manager) IsSchedulerRunning() bool {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	return m.scheduler.IsRunning()
+	return m.State() == StateRunning
 }
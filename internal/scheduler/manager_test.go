@@ -0,0 +1,239 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/serhatYilmazz/message-sender/internal/settings"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeScheduler stands in for the dispatch loop. Once asked to stop it keeps "processing" for
+// batchDuration, unless the batch is aborted through workCtx first.
+type fakeScheduler struct {
+	batchDuration time.Duration
+	active        atomic.Int32
+	maxActive     atomic.Int32
+	runs          atomic.Int32
+	aborted       atomic.Int32
+	paused        atomic.Bool
+}
+
+func (f *fakeScheduler) Run(ctx context.Context, workCtx context.Context) error {
+	f.runs.Add(1)
+	active := f.active.Add(1)
+	defer f.active.Add(-1)
+	for {
+		current := f.maxActive.Load()
+		if active <= current || f.maxActive.CompareAndSwap(current, active) {
+			break
+		}
+	}
+
+	<-ctx.Done()
+
+	timer := time.NewTimer(f.batchDuration)
+	defer timer.Stop()
+	select {
+	case <-workCtx.Done():
+		f.aborted.Add(1)
+	case <-timer.C:
+	}
+	return nil
+}
+
+func (f *fakeScheduler) Pause()                                   { f.paused.Store(true) }
+func (f *fakeScheduler) Resume()                                  { f.paused.Store(false) }
+func (f *fakeScheduler) ApplySettings(settings.SchedulerSettings) {}
+
+func newTestLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+func newTestManager(scheduler Scheduler, drainTimeout time.Duration) Manager {
+	return NewManager(scheduler, drainTimeout, newTestLogger())
+}
+
+// waitForGoroutines fails the test unless the goroutine count drops back to baseline.
+func waitForGoroutines(t *testing.T, baseline int) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > baseline {
+		if time.Now().After(deadline) {
+			t.Fatalf("goroutines leaked: %d running, %d before the test", runtime.NumGoroutine(), baseline)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestManagerLifecycle(t *testing.T) {
+	fake := &fakeScheduler{batchDuration: time.Millisecond}
+	m := newTestManager(fake, time.Second)
+	ctx := context.Background()
+
+	if err := m.PauseScheduler(); !errors.Is(err, ErrSchedulerNotRunning) {
+		t.Fatalf("pause while stopped: got %v, want %v", err, ErrSchedulerNotRunning)
+	}
+	if err := m.ResumeScheduler(); !errors.Is(err, ErrSchedulerNotRunning) {
+		t.Fatalf("resume while stopped: got %v, want %v", err, ErrSchedulerNotRunning)
+	}
+
+	steps := []struct {
+		name string
+		do   func() error
+		want State
+	}{
+		{"start", func() error { return m.StartScheduler(ctx) }, StateRunning},
+		{"start again", func() error { return m.StartScheduler(ctx) }, StateRunning},
+		{"pause", m.PauseScheduler, StatePaused},
+		{"pause again", m.PauseScheduler, StatePaused},
+		{"resume", m.ResumeScheduler, StateRunning},
+		{"stop", m.StopScheduler, StateStopped},
+		{"stop again", m.StopScheduler, StateStopped},
+		{"restart while stopped", func() error { return m.RestartScheduler(ctx) }, StateRunning},
+		{"restart while running", func() error { return m.RestartScheduler(ctx) }, StateRunning},
+		{"stop after restart", m.StopScheduler, StateStopped},
+	}
+
+	for _, step := range steps {
+		if err := step.do(); err != nil {
+			t.Fatalf("%s: unexpected error: %v", step.name, err)
+		}
+		if got := m.State(); got != step.want {
+			t.Fatalf("%s: state %s, want %s", step.name, got, step.want)
+		}
+		if running := m.IsSchedulerRunning(); running != (step.want == StateRunning) {
+			t.Fatalf("%s: IsSchedulerRunning %t in state %s", step.name, running, step.want)
+		}
+	}
+
+	if runs := fake.runs.Load(); runs != 3 {
+		t.Fatalf("scheduler ran %d times, want 3", runs)
+	}
+	if fake.paused.Load() {
+		t.Fatal("scheduler is still paused after resume")
+	}
+}
+
+func TestManagerConcurrentToggling(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+
+	fake := &fakeScheduler{batchDuration: time.Millisecond}
+	m := newTestManager(fake, 50*time.Millisecond)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < 16; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				var err error
+				switch (worker + i) % 6 {
+				case 0, 1:
+					err = m.StartScheduler(ctx)
+				case 2:
+					err = m.StopScheduler()
+				case 3:
+					err = m.RestartScheduler(ctx)
+				case 4:
+					err = m.PauseScheduler()
+				case 5:
+					err = m.ResumeScheduler()
+				}
+				if err != nil && !errors.Is(err, ErrSchedulerNotRunning) {
+					t.Errorf("unexpected error: %v", err)
+				}
+				_ = m.State()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := m.StartScheduler(ctx); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if state := m.State(); state != StateRunning && state != StatePaused {
+		t.Fatalf("state after start %s, want running or paused", state)
+	}
+
+	if err := m.StopScheduler(); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	if state := m.State(); state != StateStopped {
+		t.Fatalf("state after stop %s, want %s", state, StateStopped)
+	}
+
+	if maxActive := fake.maxActive.Load(); maxActive > 1 {
+		t.Fatalf("%d scheduler runs overlapped", maxActive)
+	}
+	if active := fake.active.Load(); active != 0 {
+		t.Fatalf("%d scheduler runs still active after stop", active)
+	}
+	waitForGoroutines(t, baseline)
+}
+
+func TestManagerStopWaitsForBatch(t *testing.T) {
+	fake := &fakeScheduler{batchDuration: 50 * time.Millisecond}
+	m := newTestManager(fake, time.Second)
+
+	if err := m.StartScheduler(context.Background()); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	started := time.Now()
+	if err := m.StopScheduler(); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+
+	if elapsed := time.Since(started); elapsed < fake.batchDuration {
+		t.Fatalf("stop returned after %s, before the batch of %s finished", elapsed, fake.batchDuration)
+	}
+	if aborted := fake.aborted.Load(); aborted != 0 {
+		t.Fatal("batch finishing within the drain timeout was aborted")
+	}
+	if state := m.State(); state != StateStopped {
+		t.Fatalf("state %s, want %s", state, StateStopped)
+	}
+}
+
+func TestManagerStopAbortsAfterDrainTimeout(t *testing.T) {
+	baseline := runtime.NumGoroutine()
+
+	drainTimeout := 50 * time.Millisecond
+	fake := &fakeScheduler{batchDuration: time.Hour}
+	m := newTestManager(fake, drainTimeout)
+
+	if err := m.StartScheduler(context.Background()); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	started := time.Now()
+	if err := m.StopScheduler(); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	elapsed := time.Since(started)
+
+	if elapsed < drainTimeout {
+		t.Fatalf("stop returned after %s, before the drain timeout of %s", elapsed, drainTimeout)
+	}
+	if elapsed > 10*drainTimeout {
+		t.Fatalf("stop took %s, the batch was not aborted after the drain timeout", elapsed)
+	}
+	if aborted := fake.aborted.Load(); aborted != 1 {
+		t.Fatalf("batch aborted %d times, want 1", aborted)
+	}
+	if state := m.State(); state != StateStopped {
+		t.Fatalf("state %s, want %s", state, StateStopped)
+	}
+	waitForGoroutines(t, baseline)
+}
@@ -2,101 +2,205 @@ package scheduler
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/serhatYilmazz/message-sender/internal/cache"
 	"github.com/serhatYilmazz/message-sender/internal/config"
 	"github.com/serhatYilmazz/message-sender/internal/outbox"
+	"github.com/serhatYilmazz/message-sender/internal/settings"
+	"github.com/serhatYilmazz/message-sender/internal/subscription"
 	"github.com/serhatYilmazz/message-sender/internal/webhook"
+	redisClient "github.com/serhatYilmazz/message-sender/pkg/redis"
 	"github.com/sirupsen/logrus"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// ErrNoSubscriptions fails an entry no enabled webhook subscription wants, so it ends up in the
+// dead letters instead of being silently dropped.
+var ErrNoSubscriptions = errors.New("no enabled webhook subscription matches the message")
+
+// Scheduler is the dispatch loop. Its lifecycle is owned by the Manager, which may run it any
+// number of times, but never twice at once.
 type Scheduler interface {
-	Start(ctx context.Context) error
-	Stop() error
-	IsRunning() bool
+	// Run dispatches until ctx is done. Work already under way at that point is finished under
+	// workCtx, which is cancelled to abort it.
+	Run(ctx context.Context, workCtx context.Context) error
+	// Pause keeps the loop alive but skips dispatching until Resume is called.
+	Pause()
+	Resume()
+	// ApplySettings takes effect from the next batch; a batch in progress finishes as it started.
+	ApplySettings(settings settings.SchedulerSettings)
 }
 
 type scheduler struct {
-	config        config.SchedulerConfig
-	outboxService outbox.Service
-	webhookSender webhook.Sender
-	cacheService  cache.Service
-	logger        *logrus.Logger
-	stopChan      chan struct{}
-	isRunning     bool
+	config              config.SchedulerConfig
+	webhookTimeout      time.Duration
+	outboxService       outbox.Service
+	subscriptionService subscription.Service
+	webhookSender       webhook.Sender
+	breaker             webhook.CircuitBreaker
+	cacheService        cache.Service
+	stream              *redisClient.Stream
+	wakeups             <-chan struct{}
+	logger              *logrus.Logger
+	election            *LeaderElection
+	paused              atomic.Bool
+	dispatchNow         chan struct{}
+	settings            atomic.Pointer[settings.SchedulerSettings]
+	reconfigured        chan struct{}
 }
 
 func NewScheduler(
 	config config.SchedulerConfig,
+	webhookConfig config.WebhookConfig,
 	outboxService outbox.Service,
+	subscriptionService subscription.Service,
 	webhookSender webhook.Sender,
+	breaker webhook.CircuitBreaker,
 	cacheService cache.Service,
+	stream *redisClient.Stream,
+	wakeups <-chan struct{},
+	election *LeaderElection,
 	logger *logrus.Logger,
 ) Scheduler {
-	return &scheduler{
-		config:        config,
-		outboxService: outboxService,
-		webhookSender: webhookSender,
-		cacheService:  cacheService,
-		logger:        logger,
-		stopChan:      make(chan struct{}),
-		isRunning:     false,
+	s := &scheduler{
+		config:              config,
+		webhookTimeout:      webhookConfig.Timeout,
+		outboxService:       outboxService,
+		subscriptionService: subscriptionService,
+		webhookSender:       webhookSender,
+		breaker:             breaker,
+		cacheService:        cacheService,
+		stream:              stream,
+		wakeups:             wakeups,
+		election:            election,
+		logger:              logger,
+		dispatchNow:         make(chan struct{}, 1),
+		reconfigured:        make(chan struct{}, 1),
 	}
+	initial := settings.FromConfig(config)
+	s.settings.Store(&initial)
+	return s
 }
 
-func (s *scheduler) Start(ctx context.Context) error {
-	if s.isRunning {
-		s.logger.WithContext(ctx).Info("[scheduler][Start] scheduler is already running")
-		return nil
+func (s *scheduler) Run(ctx context.Context, workCtx context.Context) error {
+	s.logger.WithContext(ctx).Info("[scheduler][Run] starting outbox message scheduler")
+
+	// Leadership is kept until the batch in flight is done, and only given up once Run returns.
+	if s.election != nil {
+		electionCtx, stopElection := context.WithCancel(workCtx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			s.election.run(electionCtx, s.nudge)
+		}()
+		defer func() {
+			stopElection()
+			<-done
+		}()
 	}
 
-	s.logger.WithContext(ctx).Info("[scheduler][Start] starting outbox message scheduler")
-	s.isRunning = true
+	if s.stream != nil {
+		return s.runStream(ctx, workCtx)
+	}
 
-	ticker := time.NewTicker(s.config.Interval)
+	ticker := time.NewTicker(s.currentSettings().Interval)
 	defer ticker.Stop()
 
-	s.processOutboxEntries(ctx)
+	s.processOutboxEntries(workCtx)
 
-	for {
+	var debounce <-chan time.Time
+	for ctx.Err() == nil {
 		select {
 		case <-ctx.Done():
-			s.logger.WithContext(ctx).Info("[scheduler][Start] context cancelled, stopping scheduler")
-			s.isRunning = false
-			return ctx.Err()
-		case <-s.stopChan:
-			s.logger.WithContext(ctx).Info("[scheduler][Start] stop signal received, stopping scheduler")
-			s.isRunning = false
-			return nil
 		case <-ticker.C:
-			s.processOutboxEntries(ctx)
+			s.processOutboxEntries(workCtx)
+		case <-s.dispatchNow:
+			s.processOutboxEntries(workCtx)
+		case <-s.reconfigured:
+			ticker.Reset(s.currentSettings().Interval)
+		case <-s.wakeups:
+			if debounce == nil {
+				debounce = time.After(s.config.NotifyDebounce)
+			}
+		case <-debounce:
+			debounce = nil
+			s.processOutboxEntries(workCtx)
 		}
 	}
+
+	s.logger.WithContext(ctx).Info("[scheduler][Run] stop requested, scheduler stopped")
+	return nil
 }
 
-func (s *scheduler) Stop() error {
-	if !s.isRunning {
-		s.logger.Info("[scheduler][Stop] scheduler is not running")
-		return nil
+func (s *scheduler) Pause() {
+	s.logger.Debug("[scheduler][Pause] is called")
+	s.paused.Store(true)
+}
+
+// Resume lets the loop dispatch right away instead of waiting for the next tick.
+func (s *scheduler) Resume() {
+	s.logger.Debug("[scheduler][Resume] is called")
+	if s.paused.Swap(false) {
+		s.nudge()
 	}
+}
 
-	s.logger.Info("[scheduler][Stop] stopping scheduler")
-	close(s.stopChan)
-	s.isRunning = false
-	return nil
+// nudge makes the loop dispatch without waiting for the next tick.
+func (s *scheduler) nudge() {
+	select {
+	case s.dispatchNow <- struct{}{}:
+	default:
+	}
 }
 
-func (s *scheduler) IsRunning() bool {
-	return s.isRunning
+// isLeader reports whether this instance may claim entries; without leader election every
+// instance may.
+func (s *scheduler) isLeader() bool {
+	return s.election == nil || s.election.IsLeader()
+}
+
+func (s *scheduler) ApplySettings(newSettings settings.SchedulerSettings) {
+	s.logger.Debugf("[scheduler][ApplySettings] settings: %+v", newSettings)
+	s.settings.Store(&newSettings)
+	s.outboxService.ApplySettings(newSettings)
+
+	select {
+	case s.reconfigured <- struct{}{}:
+	default:
+	}
+}
+
+func (s *scheduler) currentSettings() settings.SchedulerSettings {
+	return *s.settings.Load()
 }
 
 func (s *scheduler) processOutboxEntries(ctx context.Context) {
-	processingCtx, cancel := context.WithTimeout(ctx, s.config.SendTimeout)
+	if s.paused.Load() {
+		s.logger.WithContext(ctx).Debug("[scheduler][processOutboxEntries] scheduler is paused, skipping dispatch")
+		return
+	}
+
+	if !s.isLeader() {
+		s.logger.WithContext(ctx).Debug("[scheduler][processOutboxEntries] another instance is the scheduler leader, skipping dispatch")
+		return
+	}
+
+	if s.allBreakersOpen(ctx) {
+		s.logger.WithContext(ctx).Warn("[scheduler][processOutboxEntries] every webhook circuit breaker is open, skipping dispatch")
+		return
+	}
+
+	current := s.currentSettings()
+	processingCtx, cancel := context.WithTimeout(ctx, current.SendTimeout)
 	defer cancel()
 
 	s.logger.WithContext(processingCtx).Debug("[scheduler][processOutboxEntries] processing outbox entries")
 
-	processedCount, err := s.outboxService.ProcessUnsentEntries(processingCtx, s.config.BatchSize, s.sendMessage)
+	processedCount, err := s.outboxService.ProcessUnsentEntries(processingCtx, current.BatchSize, s.sendMessage)
 	if err != nil {
 		s.logger.WithContext(processingCtx).WithError(err).Error("failed to process outbox entries")
 		return
@@ -109,26 +213,191 @@ func (s *scheduler) processOutboxEntries(ctx context.Context) {
 	}
 }
 
-func (s *scheduler) sendMessage(ctx context.Context, entry outbox.OutboxEntry) error {
-	sendCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+// allBreakersOpen reports whether no enabled subscription would currently accept a call, in which
+// case claiming a batch would only release it again.
+func (s *scheduler) allBreakersOpen(ctx context.Context) bool {
+	if s.breaker == nil {
+		return false
+	}
+
+	subscriptions, err := s.subscriptionService.ListSubscriptions(ctx)
+	if err != nil {
+		s.logger.WithContext(ctx).WithError(err).Warn("failed to list webhook subscriptions for circuit breaker check")
+		return false
+	}
+
+	var enabled, open int
+	for _, sub := range subscriptions {
+		if !sub.Enabled {
+			continue
+		}
+		enabled++
+		if s.breaker.State(sub.Id) == webhook.BreakerOpen {
+			open++
+		}
+	}
+
+	return enabled > 0 && open == enabled
+}
+
+// sendMessage fans entry out to every matching subscription that has not received it yet. The
+// entry only counts as delivered once all of them succeeded; a failing subscription is retried
+// with the entry while the ones already done or rejected are skipped.
+func (s *scheduler) sendMessage(ctx context.Context, entry outbox.OutboxEntry) (string, error) {
+	var payload outbox.MessagePayload
+	if err := json.Unmarshal(entry.Payload, &payload); err != nil {
+		return "", fmt.Errorf("failed to unmarshal payload: %w", err)
+	}
+
+	subscriptions, err := s.subscriptionService.FindMatching(ctx, payload.PhoneNumber, payload.Tags, entry.EventType)
+	if err != nil {
+		return "", err
+	}
+
+	if len(subscriptions) == 0 {
+		return "", ErrNoSubscriptions
+	}
+
+	deliveries, err := s.outboxService.GetSubscriptionDeliveries(ctx, entry.Id)
+	if err != nil {
+		return "", err
+	}
+
+	providerMessageIds := make(map[int64]string)
+	settled := make(map[int64]outbox.SubscriptionDelivery)
+	for _, delivery := range deliveries {
+		if delivery.Status == outbox.DeliveryFailed {
+			continue
+		}
+		settled[delivery.SubscriptionId] = delivery
+		if delivery.ProviderMessageId != nil {
+			providerMessageIds[delivery.SubscriptionId] = *delivery.ProviderMessageId
+		}
+	}
+
+	var pending []subscription.Subscription
+	var rejected []error
+	for _, sub := range subscriptions {
+		delivery, ok := settled[sub.Id]
+		switch {
+		case !ok:
+			pending = append(pending, sub)
+		case delivery.Status == outbox.DeliveryRejected:
+			rejected = append(rejected, fmt.Errorf("subscription %d rejected the message earlier", sub.Id))
+		}
+	}
+
+	responses := make([]*webhook.Response, len(pending))
+	errs := make([]error, len(pending))
+	var wg sync.WaitGroup
+	for i, sub := range pending {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			responses[i], errs[i] = s.sendToSubscription(ctx, entry, sub)
+		}()
+	}
+	wg.Wait()
+
+	var failed, deferred []error
+	var retryAfter time.Duration
+	for i, sub := range pending {
+		var deliveryErr *outbox.DeliveryError
+		switch {
+		case errs[i] == nil:
+			if responses[i] != nil && responses[i].MessageId != "" {
+				providerMessageIds[sub.Id] = responses[i].MessageId
+			}
+		case errors.Is(errs[i], outbox.ErrDeliveryDeferred):
+			deferred = append(deferred, fmt.Errorf("subscription %d: %w", sub.Id, errs[i]))
+		case errors.As(errs[i], &deliveryErr) && deliveryErr.Class == outbox.FailurePermanent:
+			rejected = append(rejected, fmt.Errorf("subscription %d: %w", sub.Id, errs[i]))
+		default:
+			if deliveryErr != nil {
+				retryAfter = max(retryAfter, deliveryErr.RetryAfter)
+			}
+			failed = append(failed, fmt.Errorf("subscription %d: %w", sub.Id, errs[i]))
+		}
+	}
+
+	// Only a pure deferral leaves the attempt count alone; as soon as one endpoint really failed
+	// the entry goes through the retry policy. Rejections are final, but they only send the entry
+	// to the dead letters once no other subscription is still worth retrying.
+	if len(failed) > 0 {
+		return "", outbox.NewDeliveryError(outbox.FailureRetryable, retryAfter, errors.Join(failed...))
+	}
+	if len(deferred) > 0 {
+		return "", errors.Join(deferred...)
+	}
+	if len(rejected) > 0 {
+		return "", outbox.NewDeliveryError(outbox.FailurePermanent, 0, errors.Join(rejected...))
+	}
+
+	for _, sub := range subscriptions {
+		if providerMessageId, ok := providerMessageIds[sub.Id]; ok {
+			return providerMessageId, nil
+		}
+	}
+
+	return "", nil
+}
+
+func (s *scheduler) sendToSubscription(ctx context.Context, entry outbox.OutboxEntry, sub subscription.Subscription) (*webhook.Response, error) {
+	// A send is bounded by webhook.timeout like each of its requests, so it covers the rate limit
+	// wait too; without one only the batch deadline applies.
+	sendCtx, cancel := ctx, context.CancelFunc(func() {})
+	if s.webhookTimeout > 0 {
+		sendCtx, cancel = context.WithTimeout(ctx, s.webhookTimeout)
+	}
 	defer cancel()
 
 	s.logger.WithContext(sendCtx).
 		WithField("outbox_id", entry.Id).
 		WithField("message_id", entry.MessageId).
+		WithField("subscription_id", sub.Id).
 		Debug("sending message via webhook")
 
-	response, err := s.webhookSender.SendMessage(sendCtx, entry)
+	// The cache is written right after the endpoint accepts the message, so it still knows about
+	// deliveries whose outbox bookkeeping was lost to a crash. An unreachable cache only costs the
+	// check; receivers can still dedupe on the Idempotency-Key.
+	delivery, err := s.cacheService.GetProcessedDelivery(sendCtx, entry.MessageId, sub.Id)
+	if err != nil {
+		s.logger.WithContext(sendCtx).WithError(err).
+			WithField("outbox_id", entry.Id).
+			WithField("subscription_id", sub.Id).
+			Warn("failed to check delivery record in cache, sending anyway")
+	}
+	if delivery != nil {
+		s.logger.WithContext(sendCtx).
+			WithField("outbox_id", entry.Id).
+			WithField("message_id", entry.MessageId).
+			WithField("subscription_id", sub.Id).
+			Info("message already delivered according to cache, skipping webhook call")
+
+		_ = s.outboxService.RecordSubscriptionDelivery(ctx, entry.Id, sub.Id, delivery.ProviderMessageId, nil)
+		return &webhook.Response{MessageId: delivery.ProviderMessageId, Message: delivery.Response}, nil
+	}
+
+	response, err := s.webhookSender.SendMessage(sendCtx, entry, sub)
 	if err != nil {
 		s.logger.WithContext(sendCtx).WithError(err).
 			WithField("outbox_id", entry.Id).
 			WithField("message_id", entry.MessageId).
+			WithField("subscription_id", sub.Id).
 			Error("failed to send webhook")
-		return err
+
+		// Deferred calls and calls cut short by the batch never reached a verdict from the endpoint.
+		if !errors.Is(err, outbox.ErrDeliveryDeferred) && ctx.Err() == nil {
+			_ = s.outboxService.RecordSubscriptionDelivery(ctx, entry.Id, sub.Id, "", err)
+		}
+		return nil, err
 	}
 
+	var providerMessageId string
 	if response != nil {
-		if cacheErr := s.cacheService.RecordWebhookDelivery(sendCtx, entry, response); cacheErr != nil {
+		providerMessageId = response.MessageId
+
+		if cacheErr := s.cacheService.RecordWebhookDelivery(sendCtx, entry, sub.Id, response); cacheErr != nil {
 			// Log cache error but don't fail the operation - webhook was successful
 			s.logger.WithContext(sendCtx).WithError(cacheErr).
 				WithField("outbox_id", entry.Id).
@@ -137,6 +406,8 @@ func (s *scheduler) sendMessage(ctx context.Context, entry outbox.OutboxEntry) e
 				Warn("failed to record webhook delivery in cache, but webhook was successful")
 		}
 	}
+	// A failure to record is logged by the outbox service; the webhook call itself succeeded.
+	_ = s.outboxService.RecordSubscriptionDelivery(ctx, entry.Id, sub.Id, providerMessageId, nil)
 
-	return nil
+	return response, nil
 }
@@ -0,0 +1,81 @@
+package scheduler
+
+import (
+	"context"
+	"github.com/serhatYilmazz/message-sender/internal/settings"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+// settingsReloadInterval reloads the settings even without a notification, which is lost while
+// the listener reconnects and never arrives when no listener could be started.
+const settingsReloadInterval = time.Minute
+
+// WatchSettings applies the stored scheduler settings to manager whenever they change, until ctx
+// is done, starting or stopping the scheduler when the cluster-wide enabled flag flips. changes
+// delivers a value for every change announced on settings.NotifyChannel. It also reports the
+// state of the scheduler on this instance every settings.HeartbeatInterval.
+func WatchSettings(ctx context.Context, settingsService settings.Service, manager Manager, changes <-chan struct{}, logger *logrus.Logger) {
+	reload := time.NewTicker(settingsReloadInterval)
+	defer reload.Stop()
+	heartbeat := time.NewTicker(settings.HeartbeatInterval)
+	defer heartbeat.Stop()
+
+	reportState(ctx, settingsService, manager, logger)
+
+	var applied *settings.SchedulerSettings
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			reportState(ctx, settingsService, manager, logger)
+			continue
+		case <-changes:
+		case <-reload.C:
+		}
+
+		current, err := settingsService.GetSettings(ctx)
+		if err != nil {
+			logger.WithContext(ctx).WithError(err).Warn("failed to reload scheduler settings")
+			continue
+		}
+
+		if applied != nil && *applied == *current {
+			continue
+		}
+
+		logger.WithContext(ctx).
+			WithField("enabled", current.Enabled).
+			WithField("interval", current.Interval).
+			WithField("batch_size", current.BatchSize).
+			Info("applying changed scheduler settings")
+		manager.ApplySettings(*current)
+
+		if applied == nil || applied.Enabled != current.Enabled {
+			applyEnabled(ctx, manager, current.Enabled, logger)
+			reportState(ctx, settingsService, manager, logger)
+		}
+		applied = current
+	}
+}
+
+func applyEnabled(ctx context.Context, manager Manager, enabled bool, logger *logrus.Logger) {
+	var err error
+	if enabled {
+		err = manager.StartScheduler(ctx)
+	} else {
+		err = manager.StopScheduler()
+	}
+
+	if err != nil {
+		logger.WithContext(ctx).WithError(err).WithField("enabled", enabled).
+			Error("failed to apply the cluster-wide message sender state")
+	}
+}
+
+func reportState(ctx context.Context, settingsService settings.Service, manager Manager, logger *logrus.Logger) {
+	if err := settingsService.ReportInstanceState(ctx, string(manager.State())); err != nil {
+		logger.WithContext(ctx).WithError(err).Warn("failed to report scheduler state")
+	}
+}
@@ -0,0 +1,285 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/serhatYilmazz/message-sender/internal/config"
+	redisClient "github.com/serhatYilmazz/message-sender/pkg/redis"
+)
+
+const (
+	ModePoll   = "poll"
+	ModeStream = "stream"
+
+	streamFieldOutboxId = "outbox_id"
+	streamFieldLease    = "lease_expires_at"
+)
+
+// runStream dispatches through the Redis Stream: the relay claims due entries in Postgres and
+// publishes them, and Concurrency workers of the consumer group deliver them. Postgres stays the
+// source of truth; a message lost from the stream only delays its entry until the lease expires
+// and the relay claims it again. It returns once the workers finished the messages they hold.
+func (s *scheduler) runStream(ctx context.Context, workCtx context.Context) error {
+	if err := s.stream.EnsureGroup(ctx); err != nil {
+		s.logger.WithContext(ctx).WithError(err).Error("[scheduler][runStream] failed to set up the dispatch stream")
+		return err
+	}
+
+	streamConfig := withStreamDefaults(s.config.Stream)
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for i := 0; i < max(s.config.Concurrency, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.consumeStream(ctx, workCtx, streamConfig)
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.reclaimStream(ctx, workCtx, streamConfig)
+	}()
+
+	ticker := time.NewTicker(streamConfig.RelayInterval)
+	defer ticker.Stop()
+
+	s.relayEntries(workCtx)
+
+	var debounce <-chan time.Time
+	for ctx.Err() == nil {
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+			s.relayEntries(workCtx)
+		case <-s.dispatchNow:
+			s.relayEntries(workCtx)
+		case <-s.wakeups:
+			if debounce == nil {
+				debounce = time.After(s.config.NotifyDebounce)
+			}
+		case <-debounce:
+			debounce = nil
+			s.relayEntries(workCtx)
+		}
+	}
+
+	s.logger.WithContext(ctx).Info("[scheduler][runStream] stop requested, waiting for stream workers")
+	return nil
+}
+
+// relayEntries claims due entries and publishes them to the stream. The lease expiry travels with
+// the message so a worker can tell whether the claim is still the one that was published.
+func (s *scheduler) relayEntries(ctx context.Context) {
+	if s.paused.Load() {
+		s.logger.WithContext(ctx).Debug("[scheduler][relayEntries] scheduler is paused, skipping relay")
+		return
+	}
+
+	if !s.isLeader() {
+		s.logger.WithContext(ctx).Debug("[scheduler][relayEntries] another instance is the scheduler leader, skipping relay")
+		return
+	}
+
+	if s.allBreakersOpen(ctx) {
+		s.logger.WithContext(ctx).Warn("[scheduler][relayEntries] every webhook circuit breaker is open, skipping relay")
+		return
+	}
+
+	entries, err := s.outboxService.ClaimDueEntries(ctx, s.currentSettings().BatchSize)
+	if err != nil {
+		s.logger.WithContext(ctx).WithError(err).Error("failed to claim outbox entries for the dispatch stream")
+		return
+	}
+
+	var unpublished []int64
+	for _, entry := range entries {
+		if entry.LeaseExpiresAt == nil {
+			continue
+		}
+
+		_, err := s.stream.Publish(ctx, map[string]any{
+			streamFieldOutboxId: entry.Id,
+			streamFieldLease:    entry.LeaseExpiresAt.Format(time.RFC3339Nano),
+		})
+		if err != nil {
+			s.logger.WithContext(ctx).WithError(err).WithField("outbox_id", entry.Id).
+				Error("failed to publish outbox entry to the dispatch stream")
+			unpublished = append(unpublished, entry.Id)
+		}
+	}
+
+	if len(unpublished) > 0 {
+		s.outboxService.ReleaseClaimedEntries(ctx, unpublished)
+	}
+
+	if published := len(entries) - len(unpublished); published > 0 {
+		s.logger.WithContext(ctx).
+			WithField("published_count", published).
+			Info("relayed outbox entries to the dispatch stream")
+	}
+}
+
+// consumeStream reads one message at a time so a slow delivery does not hold back others that
+// are already read. Reading stops with ctx; a message already read is still handled under workCtx.
+func (s *scheduler) consumeStream(ctx context.Context, workCtx context.Context, streamConfig config.StreamConfig) {
+	for ctx.Err() == nil {
+		if s.paused.Load() {
+			sleep(ctx, streamConfig.Block)
+			continue
+		}
+
+		messages, err := s.stream.Read(ctx, s.config.InstanceId, 1, streamConfig.Block)
+		if err != nil {
+			if ctx.Err() == nil {
+				s.logger.WithContext(ctx).WithError(err).Error("failed to read from the dispatch stream")
+				sleep(ctx, streamConfig.Block)
+			}
+			continue
+		}
+
+		for _, message := range messages {
+			s.handleStreamMessage(workCtx, message)
+		}
+	}
+}
+
+// reclaimStream periodically claims messages left pending by workers that stopped before
+// acknowledging them.
+func (s *scheduler) reclaimStream(ctx context.Context, workCtx context.Context, streamConfig config.StreamConfig) {
+	ticker := time.NewTicker(streamConfig.ClaimIdle)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if s.paused.Load() {
+			continue
+		}
+
+		messages, err := s.stream.ClaimStuck(ctx, s.config.InstanceId, streamConfig.ClaimIdle, int64(max(s.currentSettings().BatchSize, 1)))
+		if err != nil {
+			s.logger.WithContext(ctx).WithError(err).Error("failed to claim stuck messages from the dispatch stream")
+			continue
+		}
+
+		for _, message := range messages {
+			if streamConfig.MaxDeliveries > 0 && message.Deliveries > streamConfig.MaxDeliveries {
+				s.logger.WithContext(ctx).
+					WithField("stream_message_id", message.ID).
+					WithField("deliveries", message.Deliveries).
+					Warn("dropping dispatch stream message delivered too often, the entry is retried from Postgres")
+				s.ackStreamMessage(workCtx, message.ID)
+				continue
+			}
+			s.handleStreamMessage(workCtx, message.XMessage)
+		}
+	}
+}
+
+// handleStreamMessage delivers the entry of message and acknowledges it, unless processing was
+// aborted because the scheduler did not drain in time; the message then stays pending for another
+// worker to claim.
+func (s *scheduler) handleStreamMessage(ctx context.Context, message redis.XMessage) {
+	outboxId, leaseExpiresAt, err := parseStreamMessage(message)
+	if err != nil {
+		s.logger.WithContext(ctx).WithError(err).WithField("stream_message_id", message.ID).
+			Error("dropping malformed dispatch stream message")
+		s.ackStreamMessage(ctx, message.ID)
+		return
+	}
+
+	processingCtx, cancel := context.WithTimeout(ctx, s.currentSettings().SendTimeout)
+	defer cancel()
+
+	delivered, err := s.outboxService.ProcessClaimedEntry(processingCtx, outboxId, leaseExpiresAt, s.sendMessage)
+	if err != nil {
+		s.logger.WithContext(ctx).WithError(err).WithField("outbox_id", outboxId).
+			Error("failed to process outbox entry from the dispatch stream")
+	}
+
+	if ctx.Err() != nil {
+		return
+	}
+	s.ackStreamMessage(ctx, message.ID)
+
+	if delivered {
+		s.logger.WithContext(ctx).WithField("outbox_id", outboxId).Info("delivered outbox entry from the dispatch stream")
+	}
+}
+
+func (s *scheduler) ackStreamMessage(ctx context.Context, id string) {
+	if err := s.stream.Ack(context.WithoutCancel(ctx), id); err != nil {
+		s.logger.WithContext(ctx).WithError(err).WithField("stream_message_id", id).
+			Error("failed to acknowledge dispatch stream message")
+	}
+}
+
+// withStreamDefaults fills in the stream settings that would otherwise stall the loops.
+func withStreamDefaults(streamConfig config.StreamConfig) config.StreamConfig {
+	if streamConfig.RelayInterval <= 0 {
+		streamConfig.RelayInterval = time.Second
+	}
+	if streamConfig.Block <= 0 {
+		streamConfig.Block = 5 * time.Second
+	}
+	if streamConfig.ClaimIdle <= 0 {
+		streamConfig.ClaimIdle = time.Minute
+	}
+	return streamConfig
+}
+
+func parseStreamMessage(message redis.XMessage) (int64, time.Time, error) {
+	rawId, _ := message.Values[streamFieldOutboxId].(string)
+	rawLease, _ := message.Values[streamFieldLease].(string)
+	if rawId == "" || rawLease == "" {
+		return 0, time.Time{}, errors.New("dispatch stream message is missing fields")
+	}
+
+	outboxId, err := strconv.ParseInt(rawId, 10, 64)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	leaseExpiresAt, err := time.Parse(time.RFC3339Nano, rawLease)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	return outboxId, leaseExpiresAt, nil
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// NewDispatchStream returns the stream used in stream mode, or nil in poll mode.
+func NewDispatchStream(client *redisClient.Client, schedulerConfig config.SchedulerConfig) (*redisClient.Stream, error) {
+	switch schedulerConfig.Mode {
+	case "", ModePoll:
+		return nil, nil
+	case ModeStream:
+		streamConfig := schedulerConfig.Stream
+		return client.Stream(streamConfig.Key, streamConfig.Group, streamConfig.MaxLen), nil
+	}
+	return nil, fmt.Errorf("unknown scheduler.mode %q, expected %q or %q", schedulerConfig.Mode, ModePoll, ModeStream)
+}
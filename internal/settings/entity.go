@@ -0,0 +1,50 @@
+package settings
+
+import (
+	"github.com/serhatYilmazz/message-sender/internal/config"
+	"time"
+)
+
+// NotifyChannel is the Postgres channel notified when the scheduler settings change, so every
+// instance applies them without a restart.
+const NotifyChannel = "scheduler_settings"
+
+// SchedulerSettings are the scheduler settings that can be changed at runtime. A single row in
+// Postgres holds them for every instance; the YAML values only seed it on first start. Enabled is
+// the desired state of the message sender across the cluster.
+type SchedulerSettings struct {
+	Enabled      bool
+	Interval     time.Duration
+	BatchSize    int
+	SendTimeout  time.Duration
+	DrainTimeout time.Duration
+	UpdatedAt    time.Time
+}
+
+func FromConfig(cfg config.SchedulerConfig) SchedulerSettings {
+	return SchedulerSettings{
+		Enabled:      cfg.Enabled,
+		Interval:     cfg.Interval,
+		BatchSize:    cfg.BatchSize,
+		SendTimeout:  cfg.SendTimeout,
+		DrainTimeout: cfg.DrainTimeout,
+	}
+}
+
+// ApplyTo returns cfg with the runtime settings in place of the configured values.
+func (s SchedulerSettings) ApplyTo(cfg config.SchedulerConfig) config.SchedulerConfig {
+	cfg.Enabled = s.Enabled
+	cfg.Interval = s.Interval
+	cfg.BatchSize = s.BatchSize
+	cfg.SendTimeout = s.SendTimeout
+	cfg.DrainTimeout = s.DrainTimeout
+	return cfg
+}
+
+// InstanceState is the scheduler state an instance last reported. Instances report it
+// periodically, so an instance whose heartbeat is old has most likely stopped.
+type InstanceState struct {
+	InstanceId  string
+	State       string
+	HeartbeatAt time.Time
+}
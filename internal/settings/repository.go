@@ -0,0 +1,28 @@
+package settings
+
+import (
+	"context"
+	"database/sql"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+type Repository interface {
+	Find(ctx context.Context) (*SchedulerSettings, error)
+	// Insert stores settings unless they are stored already, and reports whether it did. An
+	// enabled flag left unset by the migration that added it is filled in from settings.
+	Insert(ctx context.Context, settings *SchedulerSettings) (bool, error)
+	// Update locks the stored settings, lets modify change them and saves the result, notifying
+	// NotifyChannel on commit. An error from modify leaves the settings untouched.
+	Update(ctx context.Context, modify func(settings *SchedulerSettings) error) (*SchedulerSettings, error)
+	SaveInstanceState(ctx context.Context, state InstanceState) error
+	FindInstanceStates(ctx context.Context, heartbeatAfter time.Time) ([]InstanceState, error)
+	DeleteInstanceStates(ctx context.Context, heartbeatBefore time.Time) (int64, error)
+}
+
+func closeRows(ctx context.Context, rows *sql.Rows, logger *logrus.Logger) {
+	err := rows.Close()
+	if err != nil {
+		logger.WithContext(ctx).Errorf("Failed to close rows: %v", err)
+	}
+}
@@ -0,0 +1,188 @@
+package settings
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+const settingsColumns = `COALESCE(enabled, false), interval_ms, batch_size, send_timeout_ms, drain_timeout_ms, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+type PgRepository struct {
+	Db     *sql.DB
+	Logger *logrus.Logger
+}
+
+func (r *PgRepository) Find(ctx context.Context) (*SchedulerSettings, error) {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][Find] is called")
+
+	query := `SELECT ` + settingsColumns + ` FROM scheduler_settings WHERE id = 1`
+
+	settings, err := scanSettings(r.Db.QueryRowContext(ctx, query))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		r.Logger.WithContext(ctx).WithError(err).Error("error while querying scheduler settings")
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+func (r *PgRepository) Insert(ctx context.Context, settings *SchedulerSettings) (bool, error) {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][Insert] is called")
+
+	query := `INSERT INTO scheduler_settings (id, enabled, interval_ms, batch_size, send_timeout_ms, drain_timeout_ms, updated_at)
+			  VALUES (1, $1, $2, $3, $4, $5, $6)
+			  ON CONFLICT (id) DO UPDATE SET enabled = EXCLUDED.enabled
+			  WHERE scheduler_settings.enabled IS NULL`
+
+	result, err := r.Db.ExecContext(ctx, query, settings.Enabled, settings.Interval.Milliseconds(), settings.BatchSize,
+		settings.SendTimeout.Milliseconds(), settings.DrainTimeout.Milliseconds(), time.Now())
+	if err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Error("error while inserting scheduler settings")
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func (r *PgRepository) Update(ctx context.Context, modify func(settings *SchedulerSettings) error) (*SchedulerSettings, error) {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][Update] is called")
+
+	tx, err := r.Db.BeginTx(ctx, nil)
+	if err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Error("error while starting scheduler settings transaction")
+		return nil, err
+	}
+	defer rollback(ctx, tx, r.Logger)
+
+	query := `SELECT ` + settingsColumns + ` FROM scheduler_settings WHERE id = 1 FOR UPDATE`
+
+	settings, err := scanSettings(tx.QueryRowContext(ctx, query))
+	if err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Error("error while locking scheduler settings")
+		return nil, err
+	}
+
+	if err := modify(settings); err != nil {
+		return nil, err
+	}
+	settings.UpdatedAt = time.Now()
+
+	query = `UPDATE scheduler_settings
+			 SET enabled = $1, interval_ms = $2, batch_size = $3, send_timeout_ms = $4, drain_timeout_ms = $5, updated_at = $6
+			 WHERE id = 1`
+
+	_, err = tx.ExecContext(ctx, query, settings.Enabled, settings.Interval.Milliseconds(), settings.BatchSize,
+		settings.SendTimeout.Milliseconds(), settings.DrainTimeout.Milliseconds(), settings.UpdatedAt)
+	if err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Error("error while updating scheduler settings")
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_notify($1, '')`, NotifyChannel); err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Error("error while notifying scheduler settings change")
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Error("error while committing scheduler settings")
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+func (r *PgRepository) SaveInstanceState(ctx context.Context, state InstanceState) error {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][SaveInstanceState] is called for instance: %s", state.InstanceId)
+
+	query := `INSERT INTO scheduler_instances (instance_id, state, heartbeat_at)
+			  VALUES ($1, $2, $3)
+			  ON CONFLICT (instance_id) DO UPDATE SET state = EXCLUDED.state, heartbeat_at = EXCLUDED.heartbeat_at`
+
+	_, err := r.Db.ExecContext(ctx, query, state.InstanceId, state.State, state.HeartbeatAt)
+	if err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Errorf("error while saving scheduler state for instance: %s", state.InstanceId)
+		return err
+	}
+
+	return nil
+}
+
+func (r *PgRepository) FindInstanceStates(ctx context.Context, heartbeatAfter time.Time) ([]InstanceState, error) {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][FindInstanceStates] is called")
+
+	query := `SELECT instance_id, state, heartbeat_at FROM scheduler_instances
+			  WHERE heartbeat_at > $1
+			  ORDER BY instance_id`
+
+	rows, err := r.Db.QueryContext(ctx, query, heartbeatAfter)
+	if err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Error("error while querying scheduler instance states")
+		return nil, err
+	}
+	defer closeRows(ctx, rows, r.Logger)
+
+	states := make([]InstanceState, 0)
+	for rows.Next() {
+		var state InstanceState
+		if err := rows.Scan(&state.InstanceId, &state.State, &state.HeartbeatAt); err != nil {
+			r.Logger.WithContext(ctx).WithError(err).Error("error while scanning scheduler instance state")
+			return nil, err
+		}
+		states = append(states, state)
+	}
+
+	if err = rows.Err(); err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Error("error during rows iteration")
+		return nil, err
+	}
+
+	return states, nil
+}
+
+func (r *PgRepository) DeleteInstanceStates(ctx context.Context, heartbeatBefore time.Time) (int64, error) {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][DeleteInstanceStates] is called")
+
+	result, err := r.Db.ExecContext(ctx, `DELETE FROM scheduler_instances WHERE heartbeat_at < $1`, heartbeatBefore)
+	if err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Error("error while deleting stale scheduler instance states")
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func scanSettings(row rowScanner) (*SchedulerSettings, error) {
+	var settings SchedulerSettings
+	var intervalMs, sendTimeoutMs, drainTimeoutMs int64
+
+	err := row.Scan(&settings.Enabled, &intervalMs, &settings.BatchSize, &sendTimeoutMs, &drainTimeoutMs, &settings.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	settings.Interval = time.Duration(intervalMs) * time.Millisecond
+	settings.SendTimeout = time.Duration(sendTimeoutMs) * time.Millisecond
+	settings.DrainTimeout = time.Duration(drainTimeoutMs) * time.Millisecond
+	return &settings, nil
+}
+
+func rollback(ctx context.Context, tx *sql.Tx, logger *logrus.Logger) {
+	if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		logger.WithContext(ctx).WithError(err).Error("failed to rollback transaction")
+	}
+}
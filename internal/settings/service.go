@@ -0,0 +1,215 @@
+package settings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/serhatYilmazz/message-sender/internal/config"
+	"github.com/serhatYilmazz/message-sender/pkg/model"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+const (
+	minInterval    = time.Second
+	minSendTimeout = time.Second
+	maxBatchSize   = 1000
+
+	// HeartbeatInterval is how often instances report their scheduler state. An instance that
+	// missed three reports is left out of the cluster status, and dropped after instanceRetention.
+	HeartbeatInterval  = 10 * time.Second
+	instanceStaleAfter = 3 * HeartbeatInterval
+	instanceRetention  = time.Hour
+)
+
+var ErrInvalidSettings = errors.New("invalid scheduler settings")
+
+type Service interface {
+	// GetSettings returns the stored settings, or the configured ones while nothing is stored.
+	GetSettings(ctx context.Context) (*SchedulerSettings, error)
+	// EnsureSettings seeds the stored settings from the configuration on first start.
+	EnsureSettings(ctx context.Context) (*SchedulerSettings, error)
+	UpdateSettings(ctx context.Context, request model.SchedulerConfigRequest) (*SchedulerSettings, error)
+	// SetEnabled stores whether the message sender should run on every instance.
+	SetEnabled(ctx context.Context, enabled bool) (*SchedulerSettings, error)
+	ReportInstanceState(ctx context.Context, state string) error
+	// ListInstanceStates returns the states of the instances that reported recently.
+	ListInstanceStates(ctx context.Context) ([]InstanceState, error)
+}
+
+type service struct {
+	repository Repository
+	config     config.SchedulerConfig
+	logger     *logrus.Logger
+}
+
+func NewService(repository Repository, config config.SchedulerConfig, logger *logrus.Logger) Service {
+	return &service{
+		repository: repository,
+		config:     config,
+		logger:     logger,
+	}
+}
+
+func (s *service) GetSettings(ctx context.Context) (*SchedulerSettings, error) {
+	s.logger.WithContext(ctx).Debug("[settings.service][GetSettings] retrieving scheduler settings")
+
+	settings, err := s.repository.Find(ctx)
+	if err != nil {
+		s.logger.WithContext(ctx).WithError(err).Error("failed to get scheduler settings")
+		return nil, err
+	}
+
+	if settings == nil {
+		defaults := FromConfig(s.config)
+		return &defaults, nil
+	}
+
+	return settings, nil
+}
+
+func (s *service) EnsureSettings(ctx context.Context) (*SchedulerSettings, error) {
+	s.logger.WithContext(ctx).Debug("[settings.service][EnsureSettings] checking for stored scheduler settings")
+
+	defaults := FromConfig(s.config)
+	inserted, err := s.repository.Insert(ctx, &defaults)
+	if err != nil {
+		s.logger.WithContext(ctx).WithError(err).Error("failed to seed scheduler settings")
+		return nil, err
+	}
+
+	if inserted {
+		s.logger.WithContext(ctx).Info("seeded scheduler settings from the configuration")
+	}
+
+	return s.GetSettings(ctx)
+}
+
+func (s *service) UpdateSettings(ctx context.Context, request model.SchedulerConfigRequest) (*SchedulerSettings, error) {
+	s.logger.WithContext(ctx).Debugf("[settings.service][UpdateSettings] request: %+v", request)
+
+	// Updating locks the stored row, so it has to exist even if seeding failed at startup.
+	if _, err := s.EnsureSettings(ctx); err != nil {
+		return nil, err
+	}
+
+	settings, err := s.repository.Update(ctx, func(settings *SchedulerSettings) error {
+		if err := apply(settings, request); err != nil {
+			return err
+		}
+		return s.validate(*settings)
+	})
+	if err != nil {
+		if !errors.Is(err, ErrInvalidSettings) {
+			s.logger.WithContext(ctx).WithError(err).Error("failed to update scheduler settings")
+		}
+		return nil, err
+	}
+
+	s.logger.WithContext(ctx).
+		WithField("interval", settings.Interval).
+		WithField("batch_size", settings.BatchSize).
+		WithField("send_timeout", settings.SendTimeout).
+		WithField("drain_timeout", settings.DrainTimeout).
+		Info("updated scheduler settings")
+
+	return settings, nil
+}
+
+func (s *service) SetEnabled(ctx context.Context, enabled bool) (*SchedulerSettings, error) {
+	s.logger.WithContext(ctx).Debugf("[settings.service][SetEnabled] enabled: %t", enabled)
+
+	if _, err := s.EnsureSettings(ctx); err != nil {
+		return nil, err
+	}
+
+	settings, err := s.repository.Update(ctx, func(settings *SchedulerSettings) error {
+		settings.Enabled = enabled
+		return nil
+	})
+	if err != nil {
+		s.logger.WithContext(ctx).WithError(err).Error("failed to store message sender state")
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+func (s *service) ReportInstanceState(ctx context.Context, state string) error {
+	s.logger.WithContext(ctx).Debugf("[settings.service][ReportInstanceState] state: %s", state)
+
+	now := time.Now()
+	err := s.repository.SaveInstanceState(ctx, InstanceState{
+		InstanceId:  s.config.InstanceId,
+		State:       state,
+		HeartbeatAt: now,
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.repository.DeleteInstanceStates(ctx, now.Add(-instanceRetention)); err != nil {
+		s.logger.WithContext(ctx).WithError(err).Warn("failed to delete stale scheduler instance states")
+	}
+
+	return nil
+}
+
+func (s *service) ListInstanceStates(ctx context.Context) ([]InstanceState, error) {
+	s.logger.WithContext(ctx).Debug("[settings.service][ListInstanceStates] listing scheduler instance states")
+	return s.repository.FindInstanceStates(ctx, time.Now().Add(-instanceStaleAfter))
+}
+
+// validate rejects settings the scheduler cannot run with. The send timeout has to stay below the
+// lease, or a batch still being delivered could be claimed and sent again by another instance.
+func (s *service) validate(settings SchedulerSettings) error {
+	leaseDuration := s.config.LeaseDuration
+	if leaseDuration <= 0 {
+		leaseDuration = s.config.SendTimeout
+	}
+
+	switch {
+	case settings.Interval < minInterval:
+		return fmt.Errorf("%w: interval must be at least %s", ErrInvalidSettings, minInterval)
+	case settings.BatchSize < 1 || settings.BatchSize > maxBatchSize:
+		return fmt.Errorf("%w: batch size must be between 1 and %d", ErrInvalidSettings, maxBatchSize)
+	case settings.SendTimeout < minSendTimeout:
+		return fmt.Errorf("%w: send timeout must be at least %s", ErrInvalidSettings, minSendTimeout)
+	case settings.SendTimeout > leaseDuration:
+		return fmt.Errorf("%w: send timeout must not exceed the lease duration of %s", ErrInvalidSettings, leaseDuration)
+	case settings.DrainTimeout < 0:
+		return fmt.Errorf("%w: drain timeout must not be negative", ErrInvalidSettings)
+	}
+	return nil
+}
+
+func apply(settings *SchedulerSettings, request model.SchedulerConfigRequest) error {
+	var err error
+	if request.Interval != nil {
+		if settings.Interval, err = parseDuration("interval", *request.Interval); err != nil {
+			return err
+		}
+	}
+	if request.BatchSize != nil {
+		settings.BatchSize = *request.BatchSize
+	}
+	if request.SendTimeout != nil {
+		if settings.SendTimeout, err = parseDuration("send timeout", *request.SendTimeout); err != nil {
+			return err
+		}
+	}
+	if request.DrainTimeout != nil {
+		if settings.DrainTimeout, err = parseDuration("drain timeout", *request.DrainTimeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseDuration(name, value string) (time.Duration, error) {
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s %q is not a duration", ErrInvalidSettings, name, value)
+	}
+	return duration, nil
+}
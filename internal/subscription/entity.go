@@ -0,0 +1,45 @@
+package subscription
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// Subscription is a webhook endpoint and the messages routed to it. Empty filters match
+// everything; when several are set a message has to satisfy all of them.
+type Subscription struct {
+	Id          int64    `json:"id"`
+	URL         string   `json:"url"`
+	Secret      string   `json:"-"`
+	HasSecret   bool     `json:"hasSecret"`
+	Enabled     bool     `json:"enabled"`
+	PhonePrefix string   `json:"phonePrefix,omitempty"`
+	Tag         string   `json:"tag,omitempty"`
+	EventTypes  []string `json:"eventTypes"`
+	// PayloadFormat selects the request body format; empty means the configured webhook.payload.
+	PayloadFormat   string    `json:"payloadFormat,omitempty"`
+	PayloadTemplate string    `json:"payloadTemplate,omitempty"`
+	CreatedAt       time.Time `json:"createdAt"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
+
+func (s Subscription) Matches(phoneNumber string, tags []string, eventType string) bool {
+	if !s.Enabled {
+		return false
+	}
+
+	if s.PhonePrefix != "" && !strings.HasPrefix(phoneNumber, s.PhonePrefix) {
+		return false
+	}
+
+	if s.Tag != "" && !slices.Contains(tags, s.Tag) {
+		return false
+	}
+
+	if len(s.EventTypes) > 0 && !slices.Contains(s.EventTypes, eventType) {
+		return false
+	}
+
+	return true
+}
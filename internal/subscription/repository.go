@@ -0,0 +1,24 @@
+package subscription
+
+import (
+	"context"
+	"database/sql"
+	"github.com/sirupsen/logrus"
+)
+
+type Repository interface {
+	FindAll(ctx context.Context) ([]Subscription, error)
+	FindEnabled(ctx context.Context) ([]Subscription, error)
+	FindById(ctx context.Context, id int64) (*Subscription, error)
+	Save(ctx context.Context, subscription *Subscription) error
+	SaveIfNone(ctx context.Context, subscription *Subscription) (bool, error)
+	Update(ctx context.Context, subscription *Subscription) (bool, error)
+	Delete(ctx context.Context, id int64) (bool, error)
+}
+
+func closeRows(ctx context.Context, rows *sql.Rows, logger *logrus.Logger) {
+	err := rows.Close()
+	if err != nil {
+		logger.WithContext(ctx).Errorf("Failed to close rows: %v", err)
+	}
+}
@@ -0,0 +1,267 @@
+package subscription
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+const subscriptionColumns = `id, url, COALESCE(secret, ''), enabled, COALESCE(phone_prefix, ''), COALESCE(tag, ''),
+							 event_types, COALESCE(payload_format, ''), COALESCE(payload_template, ''), created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+type PgRepository struct {
+	Db     *sql.DB
+	Logger *logrus.Logger
+}
+
+func (r *PgRepository) FindAll(ctx context.Context) ([]Subscription, error) {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][FindAll] is called")
+
+	query := `SELECT ` + subscriptionColumns + ` FROM webhook_subscriptions ORDER BY id`
+
+	subscriptions, err := r.querySubscriptions(ctx, query)
+	if err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Error("error while querying webhook subscriptions")
+		return nil, err
+	}
+
+	return subscriptions, nil
+}
+
+func (r *PgRepository) FindEnabled(ctx context.Context) ([]Subscription, error) {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][FindEnabled] is called")
+
+	query := `SELECT ` + subscriptionColumns + ` FROM webhook_subscriptions WHERE enabled ORDER BY id`
+
+	subscriptions, err := r.querySubscriptions(ctx, query)
+	if err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Error("error while querying enabled webhook subscriptions")
+		return nil, err
+	}
+
+	return subscriptions, nil
+}
+
+func (r *PgRepository) FindById(ctx context.Context, id int64) (*Subscription, error) {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][FindById] is called for id: %d", id)
+
+	query := `SELECT ` + subscriptionColumns + ` FROM webhook_subscriptions WHERE id = $1`
+
+	subscription, err := scanSubscription(r.Db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		r.Logger.WithContext(ctx).WithError(err).Errorf("error while querying webhook subscription for id: %d", id)
+		return nil, err
+	}
+
+	return subscription, nil
+}
+
+// SaveIfNone saves subscription only while the table is empty and reports whether it did. The
+// advisory lock serialises instances seeding at the same time, and the insert runs after taking it
+// so it sees a subscription committed by whichever instance got there first.
+func (r *PgRepository) SaveIfNone(ctx context.Context, subscription *Subscription) (bool, error) {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][SaveIfNone] is called for url: %s", subscription.URL)
+
+	tx, err := r.Db.BeginTx(ctx, nil)
+	if err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Error("error while starting webhook subscription seed transaction")
+		return false, err
+	}
+	defer rollback(ctx, tx, r.Logger)
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('webhook_subscriptions.seed'))`); err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Error("error while locking webhook subscription seed")
+		return false, err
+	}
+
+	query := `INSERT INTO webhook_subscriptions (url, secret, enabled, phone_prefix, tag, event_types, payload_format, 
+			                                   payload_template, created_at, updated_at)
+			  SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+			  WHERE NOT EXISTS (SELECT 1 FROM webhook_subscriptions)
+			  RETURNING id`
+
+	now := time.Now()
+	err = tx.QueryRowContext(ctx, query,
+		subscription.URL,
+		nullIfEmpty(subscription.Secret),
+		subscription.Enabled,
+		nullIfEmpty(subscription.PhonePrefix),
+		nullIfEmpty(subscription.Tag),
+		pq.Array(subscription.EventTypes),
+		nullIfEmpty(subscription.PayloadFormat),
+		nullIfEmpty(subscription.PayloadTemplate),
+		now,
+		now).Scan(&subscription.Id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Errorf("error while seeding webhook subscription for url: %s", subscription.URL)
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Error("error while committing webhook subscription seed")
+		return false, err
+	}
+
+	subscription.HasSecret = subscription.Secret != ""
+	subscription.CreatedAt = now
+	subscription.UpdatedAt = now
+	return true, nil
+}
+
+func (r *PgRepository) Save(ctx context.Context, subscription *Subscription) error {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][Save] is called for url: %s", subscription.URL)
+
+	query := `INSERT INTO webhook_subscriptions (url, secret, enabled, phone_prefix, tag, event_types, payload_format, 
+			                                   payload_template, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			  RETURNING id`
+
+	now := time.Now()
+	err := r.Db.QueryRowContext(ctx, query,
+		subscription.URL,
+		nullIfEmpty(subscription.Secret),
+		subscription.Enabled,
+		nullIfEmpty(subscription.PhonePrefix),
+		nullIfEmpty(subscription.Tag),
+		pq.Array(subscription.EventTypes),
+		nullIfEmpty(subscription.PayloadFormat),
+		nullIfEmpty(subscription.PayloadTemplate),
+		now,
+		now).Scan(&subscription.Id)
+	if err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Errorf("error while saving webhook subscription for url: %s", subscription.URL)
+		return err
+	}
+
+	subscription.HasSecret = subscription.Secret != ""
+	subscription.CreatedAt = now
+	subscription.UpdatedAt = now
+
+	r.Logger.WithContext(ctx).WithField("subscription_id", subscription.Id).Info("webhook subscription saved")
+	return nil
+}
+
+// Update overwrites every field of the subscription. It reports false when no subscription has
+// the given id.
+func (r *PgRepository) Update(ctx context.Context, subscription *Subscription) (bool, error) {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][Update] is called for id: %d", subscription.Id)
+
+	query := `UPDATE webhook_subscriptions
+			  SET url = $1, secret = $2, enabled = $3, phone_prefix = $4, tag = $5, event_types = $6, payload_format = $7, 
+			      payload_template = $8, updated_at = $9
+			  WHERE id = $10
+			  RETURNING created_at`
+
+	now := time.Now()
+	err := r.Db.QueryRowContext(ctx, query,
+		subscription.URL,
+		nullIfEmpty(subscription.Secret),
+		subscription.Enabled,
+		nullIfEmpty(subscription.PhonePrefix),
+		nullIfEmpty(subscription.Tag),
+		pq.Array(subscription.EventTypes),
+		nullIfEmpty(subscription.PayloadFormat),
+		nullIfEmpty(subscription.PayloadTemplate),
+		now,
+		subscription.Id).Scan(&subscription.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		r.Logger.WithContext(ctx).WithError(err).Errorf("error while updating webhook subscription for id: %d", subscription.Id)
+		return false, err
+	}
+
+	subscription.HasSecret = subscription.Secret != ""
+	subscription.UpdatedAt = now
+
+	r.Logger.WithContext(ctx).WithField("subscription_id", subscription.Id).Info("webhook subscription updated")
+	return true, nil
+}
+
+// Delete removes the subscription together with its delivery records. It reports false when no
+// subscription has the given id.
+func (r *PgRepository) Delete(ctx context.Context, id int64) (bool, error) {
+	r.Logger.WithContext(ctx).Debugf("[PgRepository][Delete] is called for id: %d", id)
+
+	result, err := r.Db.ExecContext(ctx, `DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Errorf("error while deleting webhook subscription for id: %d", id)
+		return false, err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if deleted > 0 {
+		r.Logger.WithContext(ctx).WithField("subscription_id", id).Info("webhook subscription deleted")
+	}
+	return deleted > 0, nil
+}
+
+func (r *PgRepository) querySubscriptions(ctx context.Context, query string, args ...any) ([]Subscription, error) {
+	rows, err := r.Db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(ctx, rows, r.Logger)
+
+	subscriptions := make([]Subscription, 0)
+	for rows.Next() {
+		subscription, err := scanSubscription(rows)
+		if err != nil {
+			r.Logger.WithContext(ctx).WithError(err).Error("error while scanning webhook subscription")
+			return nil, err
+		}
+		subscriptions = append(subscriptions, *subscription)
+	}
+
+	if err = rows.Err(); err != nil {
+		r.Logger.WithContext(ctx).WithError(err).Error("error during rows iteration")
+		return nil, err
+	}
+
+	return subscriptions, nil
+}
+
+func scanSubscription(row rowScanner) (*Subscription, error) {
+	var subscription Subscription
+
+	err := row.Scan(&subscription.Id, &subscription.URL, &subscription.Secret, &subscription.Enabled,
+		&subscription.PhonePrefix, &subscription.Tag, pq.Array(&subscription.EventTypes), &subscription.PayloadFormat,
+		&subscription.PayloadTemplate, &subscription.CreatedAt, &subscription.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	subscription.HasSecret = subscription.Secret != ""
+	if subscription.EventTypes == nil {
+		subscription.EventTypes = []string{}
+	}
+	return &subscription, nil
+}
+
+func nullIfEmpty(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+func rollback(ctx context.Context, tx *sql.Tx, logger *logrus.Logger) {
+	if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		logger.WithContext(ctx).WithError(err).Error("failed to rollback transaction")
+	}
+}
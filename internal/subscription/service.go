@@ -0,0 +1,177 @@
+package subscription
+
+import (
+	"context"
+	"errors"
+	"github.com/serhatYilmazz/message-sender/internal/payload"
+	"github.com/serhatYilmazz/message-sender/pkg/model"
+	"github.com/sirupsen/logrus"
+)
+
+var ErrSubscriptionNotFound = errors.New("webhook subscription not found")
+
+type Service interface {
+	ListSubscriptions(ctx context.Context) ([]Subscription, error)
+	GetSubscription(ctx context.Context, id int64) (*Subscription, error)
+	CreateSubscription(ctx context.Context, request model.SubscriptionRequest) (*Subscription, error)
+	UpdateSubscription(ctx context.Context, id int64, request model.SubscriptionRequest) (*Subscription, error)
+	DeleteSubscription(ctx context.Context, id int64) error
+	FindMatching(ctx context.Context, phoneNumber string, tags []string, eventType string) ([]Subscription, error)
+	EnsureDefault(ctx context.Context, url string) error
+}
+
+type service struct {
+	repository Repository
+	logger     *logrus.Logger
+}
+
+func NewService(repository Repository, logger *logrus.Logger) Service {
+	return &service{
+		repository: repository,
+		logger:     logger,
+	}
+}
+
+func (s *service) ListSubscriptions(ctx context.Context) ([]Subscription, error) {
+	s.logger.WithContext(ctx).Debug("[subscription.service][ListSubscriptions] listing webhook subscriptions")
+	return s.repository.FindAll(ctx)
+}
+
+func (s *service) GetSubscription(ctx context.Context, id int64) (*Subscription, error) {
+	s.logger.WithContext(ctx).Debugf("[subscription.service][GetSubscription] retrieving webhook subscription: %d", id)
+	return s.repository.FindById(ctx, id)
+}
+
+func (s *service) CreateSubscription(ctx context.Context, request model.SubscriptionRequest) (*Subscription, error) {
+	s.logger.WithContext(ctx).Debugf("[subscription.service][CreateSubscription] creating webhook subscription for url: %s", request.Url)
+
+	if err := payload.Validate(request.PayloadFormat, request.PayloadTemplate); err != nil {
+		return nil, err
+	}
+
+	subscription := &Subscription{}
+	apply(subscription, request)
+
+	if err := s.repository.Save(ctx, subscription); err != nil {
+		s.logger.WithContext(ctx).WithError(err).Error("failed to save webhook subscription")
+		return nil, err
+	}
+
+	return subscription, nil
+}
+
+func (s *service) UpdateSubscription(ctx context.Context, id int64, request model.SubscriptionRequest) (*Subscription, error) {
+	s.logger.WithContext(ctx).Debugf("[subscription.service][UpdateSubscription] updating webhook subscription: %d", id)
+
+	if err := payload.Validate(request.PayloadFormat, request.PayloadTemplate); err != nil {
+		return nil, err
+	}
+
+	subscription, err := s.repository.FindById(ctx, id)
+	if err != nil {
+		s.logger.WithContext(ctx).WithError(err).WithField("subscription_id", id).Error("failed to get webhook subscription")
+		return nil, err
+	}
+
+	if subscription == nil {
+		return nil, ErrSubscriptionNotFound
+	}
+
+	apply(subscription, request)
+
+	updated, err := s.repository.Update(ctx, subscription)
+	if err != nil {
+		s.logger.WithContext(ctx).WithError(err).WithField("subscription_id", id).Error("failed to update webhook subscription")
+		return nil, err
+	}
+
+	if !updated {
+		return nil, ErrSubscriptionNotFound
+	}
+
+	return subscription, nil
+}
+
+func (s *service) DeleteSubscription(ctx context.Context, id int64) error {
+	s.logger.WithContext(ctx).Debugf("[subscription.service][DeleteSubscription] deleting webhook subscription: %d", id)
+
+	deleted, err := s.repository.Delete(ctx, id)
+	if err != nil {
+		s.logger.WithContext(ctx).WithError(err).WithField("subscription_id", id).Error("failed to delete webhook subscription")
+		return err
+	}
+
+	if !deleted {
+		return ErrSubscriptionNotFound
+	}
+
+	return nil
+}
+
+// FindMatching returns the enabled subscriptions a message with the given recipient, tags and
+// event type has to be delivered to.
+func (s *service) FindMatching(ctx context.Context, phoneNumber string, tags []string, eventType string) ([]Subscription, error) {
+	s.logger.WithContext(ctx).Debugf("[subscription.service][FindMatching] matching subscriptions for event type: %s", eventType)
+
+	subscriptions, err := s.repository.FindEnabled(ctx)
+	if err != nil {
+		s.logger.WithContext(ctx).WithError(err).Error("failed to get enabled webhook subscriptions")
+		return nil, err
+	}
+
+	matching := make([]Subscription, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		if subscription.Matches(phoneNumber, tags, eventType) {
+			matching = append(matching, subscription)
+		}
+	}
+
+	return matching, nil
+}
+
+// EnsureDefault seeds a catch-all subscription for url when no subscription exists yet, so a
+// deployment configured with a single webhook.url keeps delivering after the upgrade.
+func (s *service) EnsureDefault(ctx context.Context, url string) error {
+	s.logger.WithContext(ctx).Debug("[subscription.service][EnsureDefault] checking for existing webhook subscriptions")
+
+	if url == "" {
+		return nil
+	}
+
+	subscription := &Subscription{
+		URL:        url,
+		Enabled:    true,
+		EventTypes: []string{},
+	}
+	seeded, err := s.repository.SaveIfNone(ctx, subscription)
+	if err != nil {
+		s.logger.WithContext(ctx).WithError(err).Error("failed to seed default webhook subscription")
+		return err
+	}
+
+	if !seeded {
+		return nil
+	}
+
+	s.logger.WithContext(ctx).WithField("subscription_id", subscription.Id).Info("seeded default webhook subscription from webhook.url")
+	return nil
+}
+
+func apply(subscription *Subscription, request model.SubscriptionRequest) {
+	subscription.URL = request.Url
+	if request.Secret != nil {
+		subscription.Secret = *request.Secret
+	}
+	subscription.Enabled = request.Enabled == nil || *request.Enabled
+	subscription.PhonePrefix = request.PhonePrefix
+	subscription.Tag = request.Tag
+	subscription.EventTypes = request.EventTypes
+	if subscription.EventTypes == nil {
+		subscription.EventTypes = []string{}
+	}
+	subscription.PayloadFormat = request.PayloadFormat
+	subscription.PayloadTemplate = ""
+	if request.PayloadFormat == payload.FormatTemplate {
+		subscription.PayloadTemplate = request.PayloadTemplate
+	}
+}
@@ -0,0 +1,209 @@
+package webhook
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/serhatYilmazz/message-sender/internal/config"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	AuthNone   = "none"
+	AuthBearer = "bearer"
+	AuthBasic  = "basic"
+	AuthAPIKey = "api_key"
+	AuthOAuth2 = "oauth2"
+)
+
+// tokenExpirySkew renews an OAuth2 token this long before it actually expires, so a request is
+// never sent with a token that runs out on the way.
+const tokenExpirySkew = 30 * time.Second
+
+// Authenticator adds credentials to an outgoing webhook request.
+type Authenticator interface {
+	Authenticate(ctx context.Context, req *http.Request) error
+	// Invalidate drops cached credentials after the endpoint rejected them. It reports whether
+	// retrying with fresh credentials can make a difference.
+	Invalidate() bool
+}
+
+// NewAuthenticator builds the Authenticator selected by cfg.Type. The OAuth2 token endpoint is
+// called through httpClient so it shares the webhook's TLS settings.
+func NewAuthenticator(cfg config.AuthConfig, httpClient *http.Client) (Authenticator, error) {
+	switch strings.ToLower(cfg.Type) {
+	case "", AuthNone:
+		return noAuth{}, nil
+	case AuthBearer:
+		if cfg.Token == "" {
+			return nil, errors.New("webhook.auth.token is required for bearer auth")
+		}
+		return staticAuth(func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer "+cfg.Token)
+		}), nil
+	case AuthBasic:
+		if cfg.Username == "" {
+			return nil, errors.New("webhook.auth.username is required for basic auth")
+		}
+		return staticAuth(func(req *http.Request) {
+			req.SetBasicAuth(cfg.Username, cfg.Password)
+		}), nil
+	case AuthAPIKey:
+		if cfg.APIKeyHeader == "" || cfg.APIKey == "" {
+			return nil, errors.New("webhook.auth.api_key_header and webhook.auth.api_key are required for api_key auth")
+		}
+		return staticAuth(func(req *http.Request) {
+			req.Header.Set(cfg.APIKeyHeader, cfg.APIKey)
+		}), nil
+	case AuthOAuth2:
+		if cfg.OAuth2.TokenURL == "" || cfg.OAuth2.ClientID == "" {
+			return nil, errors.New("webhook.auth.oauth2.token_url and webhook.auth.oauth2.client_id are required for oauth2 auth")
+		}
+		return &oauth2Auth{config: cfg.OAuth2, httpClient: httpClient}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported webhook.auth.type: %s", cfg.Type)
+}
+
+type noAuth struct{}
+
+func (noAuth) Authenticate(context.Context, *http.Request) error { return nil }
+
+func (noAuth) Invalidate() bool { return false }
+
+type staticAuth func(req *http.Request)
+
+func (a staticAuth) Authenticate(_ context.Context, req *http.Request) error {
+	a(req)
+	return nil
+}
+
+func (staticAuth) Invalidate() bool { return false }
+
+// oauth2Auth implements the OAuth2 client-credentials grant. The access token is shared by all
+// requests until it expires or the endpoint answers 401.
+type oauth2Auth struct {
+	config     config.OAuth2Config
+	httpClient *http.Client
+	mu         sync.Mutex
+	token      string
+	expiresAt  time.Time
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+func (a *oauth2Auth) Authenticate(ctx context.Context, req *http.Request) error {
+	token, err := a.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+func (a *oauth2Auth) Invalidate() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.token = ""
+	return true
+}
+
+// accessToken holds mu while fetching so concurrent sends wait for one token request instead of
+// each starting their own.
+func (a *oauth2Auth) accessToken(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != "" && (a.expiresAt.IsZero() || time.Now().Before(a.expiresAt)) {
+		return a.token, nil
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(a.config.Scopes) > 0 {
+		form.Set("scope", strings.Join(a.config.Scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.config.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(a.config.ClientID), url.QueryEscape(a.config.ClientSecret))
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch oauth2 token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("oauth2 token endpoint returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var token tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to decode oauth2 token response: %w", err)
+	}
+
+	if token.AccessToken == "" {
+		return "", errors.New("oauth2 token response has no access_token")
+	}
+
+	a.token = token.AccessToken
+	a.expiresAt = time.Time{}
+	if token.ExpiresIn > 0 {
+		a.expiresAt = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - tokenExpirySkew)
+	}
+
+	return a.token, nil
+}
+
+// newTLSConfig returns nil when no client certificate or CA is configured, leaving the default
+// transport settings in place.
+func newTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	if cfg.CertFile == "" && cfg.KeyFile == "" && cfg.CAFile == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		certificate, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load webhook client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	if cfg.CAFile != "" {
+		caPem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read webhook CA file: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPem) {
+			return nil, fmt.Errorf("no certificates found in webhook CA file: %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,191 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/serhatYilmazz/message-sender/internal/config"
+	"github.com/serhatYilmazz/message-sender/internal/outbox"
+	"github.com/serhatYilmazz/message-sender/internal/subscription"
+	"github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+// ErrCircuitOpen is returned instead of calling the webhook while the breaker is open. It wraps
+// outbox.ErrDeliveryDeferred so the entry is requeued without consuming an attempt.
+var ErrCircuitOpen = fmt.Errorf("webhook circuit breaker is open: %w", outbox.ErrDeliveryDeferred)
+
+type CircuitBreaker interface {
+	State(subscriptionId int64) BreakerState
+	States() map[int64]BreakerState
+}
+
+// BreakerSender is a Sender guarded by one closed/open/half-open circuit breaker per subscription,
+// so an endpoint that is down does not hold back deliveries to the others.
+type BreakerSender struct {
+	sender   Sender
+	config   config.CircuitBreakerConfig
+	logger   *logrus.Logger
+	mu       sync.Mutex
+	breakers map[int64]*breaker
+}
+
+type breaker struct {
+	state            BreakerState
+	failures         int
+	successes        int
+	halfOpenInFlight int
+	openedAt         time.Time
+}
+
+func NewCircuitBreakerSender(sender Sender, config config.CircuitBreakerConfig, logger *logrus.Logger) *BreakerSender {
+	return &BreakerSender{
+		sender:   sender,
+		config:   config,
+		logger:   logger,
+		breakers: make(map[int64]*breaker),
+	}
+}
+
+func (b *BreakerSender) SendMessage(ctx context.Context, entry outbox.OutboxEntry, subscription subscription.Subscription) (*Response, error) {
+	if !b.acquire(subscription.Id) {
+		b.logger.WithContext(ctx).
+			WithField("outbox_id", entry.Id).
+			WithField("subscription_id", subscription.Id).
+			Debug("[webhook.breaker][SendMessage] circuit open, skipping webhook call")
+		return nil, ErrCircuitOpen
+	}
+
+	response, err := b.sender.SendMessage(ctx, entry, subscription)
+	b.record(ctx, subscription.Id, err)
+	return response, err
+}
+
+// State reports the breaker state of a subscription, including an open breaker whose timeout has
+// passed as half-open since the next call will be let through as a trial.
+func (b *BreakerSender) State(subscriptionId int64) BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.stateOf(b.breakers[subscriptionId])
+}
+
+// States reports the state of every subscription that has been called so far.
+func (b *BreakerSender) States() map[int64]BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	states := make(map[int64]BreakerState, len(b.breakers))
+	for subscriptionId, endpoint := range b.breakers {
+		states[subscriptionId] = b.stateOf(endpoint)
+	}
+	return states
+}
+
+// stateOf must be called with mu held.
+func (b *BreakerSender) stateOf(endpoint *breaker) BreakerState {
+	if endpoint == nil {
+		return BreakerClosed
+	}
+
+	if endpoint.state == BreakerOpen && time.Since(endpoint.openedAt) >= b.config.OpenTimeout {
+		return BreakerHalfOpen
+	}
+	return endpoint.state
+}
+
+func (b *BreakerSender) acquire(subscriptionId int64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	endpoint, ok := b.breakers[subscriptionId]
+	if !ok {
+		endpoint = &breaker{state: BreakerClosed}
+		b.breakers[subscriptionId] = endpoint
+	}
+
+	switch endpoint.state {
+	case BreakerOpen:
+		if time.Since(endpoint.openedAt) < b.config.OpenTimeout {
+			return false
+		}
+		b.transition(subscriptionId, endpoint, BreakerHalfOpen)
+		fallthrough
+	case BreakerHalfOpen:
+		if endpoint.halfOpenInFlight >= max(b.config.HalfOpenMaxRequests, 1) {
+			return false
+		}
+		endpoint.halfOpenInFlight++
+	}
+
+	return true
+}
+
+func (b *BreakerSender) record(ctx context.Context, subscriptionId int64, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	endpoint := b.breakers[subscriptionId]
+	if endpoint.state == BreakerHalfOpen && endpoint.halfOpenInFlight > 0 {
+		endpoint.halfOpenInFlight--
+	}
+
+	// A call abandoned because the caller gave up says nothing about the endpoint's health.
+	if err != nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) && ctx.Err() != nil {
+		return
+	}
+
+	// The endpoint answered and refused this message in particular; that is not an outage.
+	var deliveryErr *outbox.DeliveryError
+	if errors.As(err, &deliveryErr) && deliveryErr.Class == outbox.FailurePermanent {
+		err = nil
+	}
+
+	if err != nil {
+		endpoint.failures++
+		endpoint.successes = 0
+		if endpoint.state == BreakerHalfOpen || endpoint.failures >= max(b.config.FailureThreshold, 1) {
+			b.transition(subscriptionId, endpoint, BreakerOpen)
+		}
+		return
+	}
+
+	endpoint.failures = 0
+	if endpoint.state == BreakerHalfOpen {
+		endpoint.successes++
+		if endpoint.successes >= max(b.config.SuccessThreshold, 1) {
+			b.transition(subscriptionId, endpoint, BreakerClosed)
+		}
+	}
+}
+
+// transition must be called with mu held.
+func (b *BreakerSender) transition(subscriptionId int64, endpoint *breaker, next BreakerState) {
+	if endpoint.state == next {
+		return
+	}
+
+	b.logger.WithField("subscription_id", subscriptionId).
+		WithField("from", endpoint.state).
+		WithField("to", next).
+		Warn("[webhook.breaker] circuit breaker state changed")
+
+	endpoint.state = next
+	endpoint.successes = 0
+	endpoint.halfOpenInFlight = 0
+	switch next {
+	case BreakerOpen:
+		endpoint.openedAt = time.Now()
+	case BreakerClosed:
+		endpoint.failures = 0
+	}
+}
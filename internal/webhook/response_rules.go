@@ -0,0 +1,251 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/serhatYilmazz/message-sender/internal/config"
+	"github.com/serhatYilmazz/message-sender/internal/outbox"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type responseClass int
+
+const (
+	responseRetryable responseClass = iota
+	responseSuccess
+	responsePermanent
+)
+
+var (
+	codePattern     = regexp.MustCompile(`^[1-5]([0-9]{2}|xx)$`)
+	pathSegment     = regexp.MustCompile(`^([^.\[\]]*)((?:\[[0-9]+\])*)$`)
+	pathIndex       = regexp.MustCompile(`\[([0-9]+)\]`)
+	errInvalidRules = errors.New("invalid webhook.response configuration")
+)
+
+// responseRules classifies webhook responses and pulls the provider message ID out of successful
+// ones.
+type responseRules struct {
+	exact         map[int]responseClass
+	classes       map[int]responseClass
+	messageIdPath []string
+	maxRetryAfter time.Duration
+}
+
+// defaultCodes behave like a plain HTTP client: 2xx succeeds, client errors other than timeouts
+// and throttling are permanent and everything else is worth another try. Rejected credentials are
+// retried too, since they are usually fixed by rotating a secret or token rather than by changing
+// the message.
+var defaultCodes = map[responseClass][]string{
+	responseSuccess:   {"2xx"},
+	responseRetryable: {"401", "403", "408", "425", "429", "5xx"},
+	responsePermanent: {"4xx"},
+}
+
+func newResponseRules(cfg config.ResponseConfig) (*responseRules, error) {
+	rules := &responseRules{
+		exact:         make(map[int]responseClass),
+		classes:       make(map[int]responseClass),
+		maxRetryAfter: cfg.MaxRetryAfter,
+	}
+
+	configured := map[responseClass][]string{
+		responseSuccess:   cfg.SuccessCodes,
+		responseRetryable: cfg.RetryableCodes,
+		responsePermanent: cfg.PermanentCodes,
+	}
+	for class, patterns := range configured {
+		for _, pattern := range patterns {
+			if err := rules.add(pattern, class, false); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Each outcome left empty falls back to its defaults on its own, so configuring only some of
+	// them never leaves 2xx unclassified and re-sent. Defaults never override a configured code.
+	for class, patterns := range defaultCodes {
+		if len(configured[class]) > 0 {
+			continue
+		}
+		for _, pattern := range patterns {
+			if err := rules.add(pattern, class, true); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	path, err := parsePath(cfg.MessageIdPath)
+	if err != nil {
+		return nil, err
+	}
+	rules.messageIdPath = path
+
+	return rules, nil
+}
+
+// add registers pattern for class. A fallback pattern is skipped when its code or class has been
+// configured already; otherwise listing it under two outcomes is an error.
+func (r *responseRules) add(pattern string, class responseClass, fallback bool) error {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	if !codePattern.MatchString(pattern) {
+		return fmt.Errorf("%w: status code pattern %q", errInvalidRules, pattern)
+	}
+
+	target := r.exact
+	key, _ := strconv.Atoi(pattern)
+	if strings.HasSuffix(pattern, "xx") {
+		target = r.classes
+		key = int(pattern[0]-'0') * 100
+	}
+
+	if existing, ok := target[key]; ok {
+		if fallback || existing == class {
+			return nil
+		}
+		return fmt.Errorf("%w: %s is listed under more than one outcome", errInvalidRules, pattern)
+	}
+	target[key] = class
+	return nil
+}
+
+func (r *responseRules) classify(statusCode int) responseClass {
+	if class, ok := r.exact[statusCode]; ok {
+		return class
+	}
+	if class, ok := r.classes[statusCode/100*100]; ok {
+		return class
+	}
+	return responseRetryable
+}
+
+// failure turns a non-success response into an outbox.DeliveryError. Retry-After is only honored
+// on 429 and 503, where HTTP defines it as a back-off hint.
+func (r *responseRules) failure(resp *http.Response, class responseClass) error {
+	err := fmt.Errorf("webhook returned status: %d", resp.StatusCode)
+	if class == responsePermanent {
+		return outbox.NewDeliveryError(outbox.FailurePermanent, 0, err)
+	}
+
+	var retryAfter time.Duration
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		if r.maxRetryAfter > 0 && retryAfter > r.maxRetryAfter {
+			retryAfter = r.maxRetryAfter
+		}
+	}
+
+	return outbox.NewDeliveryError(outbox.FailureRetryable, retryAfter, err)
+}
+
+// messageId extracts the provider message ID from a response body. Numbers are returned in their
+// JSON form; anything else that is not a string yields no ID.
+func (r *responseRules) messageId(body []byte) (string, bool) {
+	if len(r.messageIdPath) == 0 || len(bytes.TrimSpace(body)) == 0 {
+		return "", false
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var document any
+	if err := decoder.Decode(&document); err != nil {
+		return "", false
+	}
+
+	for _, step := range r.messageIdPath {
+		if isIndex(step) {
+			index, _ := strconv.Atoi(step[1 : len(step)-1])
+			items, ok := document.([]any)
+			if !ok || index >= len(items) {
+				return "", false
+			}
+			document = items[index]
+			continue
+		}
+
+		object, ok := document.(map[string]any)
+		if !ok {
+			return "", false
+		}
+		document, ok = object[step]
+		if !ok {
+			return "", false
+		}
+	}
+
+	switch value := document.(type) {
+	case string:
+		return value, value != ""
+	case json.Number:
+		return value.String(), true
+	}
+	return "", false
+}
+
+// parsePath accepts the dotted JSONPath subset "$.data.items[0].id". Array indexes are kept as
+// "[n]" steps so they cannot be confused with object keys made of digits.
+func parsePath(path string) ([]string, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, nil
+	}
+
+	if path != "$" && !strings.HasPrefix(path, "$.") && !strings.HasPrefix(path, "$[") {
+		return nil, fmt.Errorf("%w: message_id_path must start with $", errInvalidRules)
+	}
+
+	steps := make([]string, 0)
+	rest := strings.TrimPrefix(path, "$")
+	rest = strings.TrimPrefix(rest, ".")
+	if rest == "" {
+		return steps, nil
+	}
+
+	for _, segment := range strings.Split(rest, ".") {
+		match := pathSegment.FindStringSubmatch(segment)
+		if match == nil || (match[1] == "" && match[2] == "") {
+			return nil, fmt.Errorf("%w: unsupported message_id_path segment %q", errInvalidRules, segment)
+		}
+
+		if match[1] != "" {
+			steps = append(steps, match[1])
+		}
+		for _, index := range pathIndex.FindAllStringSubmatch(match[2], -1) {
+			steps = append(steps, "["+index[1]+"]")
+		}
+	}
+
+	return steps, nil
+}
+
+func isIndex(step string) bool {
+	return strings.HasPrefix(step, "[") && strings.HasSuffix(step, "]")
+}
+
+// parseRetryAfter reads either delay-seconds or an HTTP date. Unparseable or past values give 0.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+
+	return 0
+}
@@ -0,0 +1,95 @@
+package webhook
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/serhatYilmazz/message-sender/internal/config"
+)
+
+func TestResponseRulesClassify(t *testing.T) {
+	tests := []struct {
+		name   string
+		config config.ResponseConfig
+		want   map[int]responseClass
+	}{
+		{
+			name:   "defaults",
+			config: config.ResponseConfig{},
+			want: map[int]responseClass{
+				200: responseSuccess,
+				204: responseSuccess,
+				400: responsePermanent,
+				401: responseRetryable,
+				403: responseRetryable,
+				429: responseRetryable,
+				500: responseRetryable,
+			},
+		},
+		{
+			name:   "only permanent codes",
+			config: config.ResponseConfig{PermanentCodes: []string{"409"}},
+			want: map[int]responseClass{
+				200: responseSuccess,
+				202: responseSuccess,
+				409: responsePermanent,
+				400: responseRetryable,
+				503: responseRetryable,
+			},
+		},
+		{
+			name:   "only retryable codes",
+			config: config.ResponseConfig{RetryableCodes: []string{"4xx"}},
+			want: map[int]responseClass{
+				200: responseSuccess,
+				400: responseRetryable,
+				404: responseRetryable,
+				500: responseRetryable,
+			},
+		},
+		{
+			name:   "only success codes",
+			config: config.ResponseConfig{SuccessCodes: []string{"200"}},
+			want: map[int]responseClass{
+				200: responseSuccess,
+				201: responseRetryable,
+				400: responsePermanent,
+				429: responseRetryable,
+			},
+		},
+		{
+			name:   "configured code wins over a default",
+			config: config.ResponseConfig{PermanentCodes: []string{"401"}},
+			want: map[int]responseClass{
+				200: responseSuccess,
+				401: responsePermanent,
+				403: responseRetryable,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rules, err := newResponseRules(test.config)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for code, want := range test.want {
+				if got := rules.classify(code); got != want {
+					t.Errorf("classify(%d) = %d, want %d", code, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestResponseRulesRejectConflicts(t *testing.T) {
+	_, err := newResponseRules(config.ResponseConfig{
+		RetryableCodes: []string{"409"},
+		PermanentCodes: []string{"409"},
+	})
+	if !errors.Is(err, errInvalidRules) {
+		t.Fatalf("got %v, want %v", err, errInvalidRules)
+	}
+}
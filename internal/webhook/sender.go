@@ -3,86 +3,278 @@ package webhook
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/google/uuid"
 	"github.com/serhatYilmazz/message-sender/internal/config"
+	"github.com/serhatYilmazz/message-sender/internal/delivery"
 	"github.com/serhatYilmazz/message-sender/internal/outbox"
+	"github.com/serhatYilmazz/message-sender/internal/payload"
+	"github.com/serhatYilmazz/message-sender/internal/subscription"
+	"github.com/serhatYilmazz/message-sender/pkg/ratelimit"
+	"github.com/serhatYilmazz/message-sender/pkg/signature"
 	"github.com/sirupsen/logrus"
+	"io"
 	"net/http"
+	"strconv"
 	"time"
 )
 
+// placeholderSecret is the example signing secret from older sample configs. Signing with a
+// value anyone can look up would only give receivers false confidence.
+const placeholderSecret = "change-me"
+
+// maxResponseBodySize bounds how much of a webhook response is read looking for the provider
+// message ID or kept in the delivery history.
+const maxResponseBodySize = 64 << 10
+
+// HeaderIdempotencyKey carries a key that stays the same across every attempt to deliver an outbox
+// entry to a subscription, so receivers can drop duplicates.
+const HeaderIdempotencyKey = "Idempotency-Key"
+
 type Sender interface {
-	SendMessage(ctx context.Context, entry outbox.OutboxEntry) (*Response, error)
+	SendMessage(ctx context.Context, entry outbox.OutboxEntry, subscription subscription.Subscription) (*Response, error)
 }
 
 type sender struct {
-	config     config.WebhookConfig
-	httpClient *http.Client
-	logger     *logrus.Logger
+	config           config.WebhookConfig
+	httpClient       *http.Client
+	authenticator    Authenticator
+	renderer         *payload.Renderer
+	rules            *responseRules
+	globalLimiter    ratelimit.Limiter
+	recipientLimiter ratelimit.Limiter
+	history          delivery.Service
+	logger           *logrus.Logger
 }
 
-func NewSender(config config.WebhookConfig, logger *logrus.Logger) Sender {
-	return &sender{
-		config: config,
-		httpClient: &http.Client{
-			Timeout: config.Timeout,
-		},
-		logger: logger,
+// NewSender creates a Sender that records every request it makes in history. Either limiter may be
+// nil to leave that dimension unlimited. It fails when the configured credentials, signing
+// secrets, TLS files, payload template or response rules are invalid.
+func NewSender(config config.WebhookConfig, globalLimiter, recipientLimiter ratelimit.Limiter, history delivery.Service, logger *logrus.Logger) (Sender, error) {
+	for _, secret := range config.Signing.Secrets {
+		if secret == "" || secret == placeholderSecret {
+			return nil, errors.New("webhook.signing.secrets must not contain an empty or placeholder secret")
+		}
+	}
+
+	tlsConfig, err := newTLSConfig(config.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := &http.Client{
+		Timeout: config.Timeout,
+	}
+	if tlsConfig != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		httpClient.Transport = transport
+	}
+
+	authenticator, err := NewAuthenticator(config.Auth, httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	renderer, err := payload.NewRenderer(config.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := newResponseRules(config.Response)
+	if err != nil {
+		return nil, err
 	}
+
+	return &sender{
+		config:           config,
+		httpClient:       httpClient,
+		authenticator:    authenticator,
+		renderer:         renderer,
+		rules:            rules,
+		globalLimiter:    globalLimiter,
+		recipientLimiter: recipientLimiter,
+		history:          history,
+		logger:           logger,
+	}, nil
 }
 
-func (s *sender) SendMessage(ctx context.Context, entry outbox.OutboxEntry) (*Response, error) {
-	s.logger.WithContext(ctx).Debugf("[webhook.sender][SendMessage] sending message with ID: %d", entry.Id)
+func (s *sender) SendMessage(ctx context.Context, entry outbox.OutboxEntry, subscription subscription.Subscription) (*Response, error) {
+	s.logger.WithContext(ctx).Debugf("[webhook.sender][SendMessage] sending message with ID: %d to subscription: %d", entry.Id, subscription.Id)
 
 	var messagePayload outbox.MessagePayload
 	err := json.Unmarshal(entry.Payload, &messagePayload)
 	if err != nil {
 		s.logger.WithContext(ctx).WithError(err).Errorf("failed to unmarshal payload for outbox entry ID: %d", entry.Id)
-		return nil, fmt.Errorf("failed to unmarshal payload: %w", err)
+		return nil, outbox.NewDeliveryError(outbox.FailurePermanent, 0, fmt.Errorf("failed to unmarshal payload: %w", err))
 	}
 
-	webhookPayload := map[string]interface{}{
-		"id":          messagePayload.Id,
-		"content":     messagePayload.Content,
-		"phoneNumber": messagePayload.PhoneNumber,
-		"timestamp":   entry.CreatedAt.Format(time.RFC3339),
+	// Waiting for a token is our own throttling, not a failure of the endpoint, so a wait that runs
+	// out of time defers the entry instead of consuming one of its attempts.
+	if err := s.waitForRateLimit(ctx, messagePayload.PhoneNumber); err != nil {
+		s.logger.WithContext(ctx).WithError(err).Warnf("rate limit wait aborted for outbox entry ID: %d", entry.Id)
+		return nil, fmt.Errorf("rate limit wait aborted: %w: %w", outbox.ErrDeliveryDeferred, err)
 	}
 
-	payloadBytes, err := json.Marshal(webhookPayload)
+	body, err := s.renderer.Render(subscription.PayloadFormat, subscription.PayloadTemplate, payload.Message{
+		OutboxId:    entry.Id,
+		EventType:   entry.EventType,
+		Id:          messagePayload.Id,
+		Content:     messagePayload.Content,
+		PhoneNumber: messagePayload.PhoneNumber,
+		Tags:        messagePayload.Tags,
+		CreatedAt:   entry.CreatedAt,
+	})
 	if err != nil {
-		s.logger.WithContext(ctx).WithError(err).Errorf("failed to marshal webhook payload for outbox entry ID: %d", entry.Id)
-		return nil, fmt.Errorf("failed to marshal webhook payload: %w", err)
+		s.logger.WithContext(ctx).WithError(err).Errorf("failed to render webhook payload for outbox entry ID: %d", entry.Id)
+		return nil, outbox.NewDeliveryError(outbox.FailurePermanent, 0, fmt.Errorf("failed to render webhook payload: %w", err))
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.URL, bytes.NewBuffer(payloadBytes))
-	if err != nil {
-		s.logger.WithContext(ctx).WithError(err).Errorf("failed to create HTTP request for outbox entry ID: %d", entry.Id)
-		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	requestHash := sha256.Sum256(body.Data)
+	attempt := &delivery.Delivery{
+		OutboxId:       entry.Id,
+		MessageId:      entry.MessageId,
+		SubscriptionId: subscription.Id,
+		RequestHash:    hex.EncodeToString(requestHash[:]),
+		AttemptedAt:    time.Now(),
 	}
 
-	resp, err := s.httpClient.Do(req)
+	resp, err := s.post(ctx, body, IdempotencyKey(entry.Id, subscription.Id), subscription)
 	if err != nil {
 		s.logger.WithContext(ctx).WithError(err).Errorf("failed to send webhook for outbox entry ID: %d", entry.Id)
-		return nil, fmt.Errorf("failed to send webhook: %w", err)
+		attempt.Error = err.Error()
+		s.recordAttempt(ctx, attempt)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+	// For a successful response the receiver has accepted the message, so a body we cannot read
+	// or parse only costs the provider message ID, never a re-send.
+	responseBody, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBodySize))
+	if err != nil {
+		s.logger.WithContext(ctx).WithError(err).Warnf("failed to read webhook response body for outbox entry ID: %d", entry.Id)
+	}
+	attempt.StatusCode = resp.StatusCode
+	attempt.ResponseBody = string(responseBody)
+
+	if class := s.rules.classify(resp.StatusCode); class != responseSuccess {
 		s.logger.WithContext(ctx).Errorf("webhook returned non-success status %d for outbox entry ID: %d", resp.StatusCode, entry.Id)
-		return nil, fmt.Errorf("webhook returned status: %d", resp.StatusCode)
+		failure := s.rules.failure(resp, class)
+		attempt.Error = failure.Error()
+		s.recordAttempt(ctx, attempt)
+		return nil, failure
 	}
 
+	// Message is informational only, so a body of another shape is fine. Without a configured
+	// path the provider message ID is read from the messageId field.
 	var response Response
-	if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		s.logger.WithContext(ctx).Errorf("webhook response could not be decoded")
-		return nil, err
+	_ = json.Unmarshal(responseBody, &response)
+	if len(s.rules.messageIdPath) > 0 {
+		messageId, ok := s.rules.messageId(responseBody)
+		if !ok {
+			s.logger.WithContext(ctx).WithField("outbox_id", entry.Id).
+				Warn("webhook response has no provider message ID at the configured path")
+		}
+		response.MessageId = messageId
 	}
 
+	attempt.Delivered = true
+	attempt.ProviderMessageId = response.MessageId
+	s.recordAttempt(ctx, attempt)
+
 	s.logger.WithContext(ctx).WithField("outbox_id", entry.Id).
+		WithField("subscription_id", subscription.Id).
 		WithField("response", response).
 		WithField("message_id", messagePayload.Id).
 		Info("webhook sent successfully")
 
 	return &response, nil
 }
+
+// post sends body to the subscription. When the endpoint answers 401 and the authenticator can
+// obtain fresh credentials (OAuth2), the request is sent once more with them.
+func (s *sender) post(ctx context.Context, body *payload.Body, idempotencyKey string, subscription subscription.Subscription) (*http.Response, error) {
+	for retried := false; ; retried = true {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(body.Data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+		}
+		s.setHeaders(req, body, idempotencyKey, subscription)
+
+		if err := s.authenticator.Authenticate(ctx, req); err != nil {
+			return nil, fmt.Errorf("failed to authenticate webhook request: %w", err)
+		}
+
+		resp, err := s.httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to send webhook: %w", err)
+		}
+
+		if resp.StatusCode != http.StatusUnauthorized || retried || !s.authenticator.Invalidate() {
+			return resp, nil
+		}
+
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		s.logger.WithContext(ctx).WithField("subscription_id", subscription.Id).
+			Warn("webhook rejected the credentials, retrying with a fresh token")
+	}
+}
+
+// recordAttempt stores the attempt in the delivery history. A failure is logged by the history
+// service and does not change the outcome of the send.
+func (s *sender) recordAttempt(ctx context.Context, attempt *delivery.Delivery) {
+	attempt.LatencyMs = time.Since(attempt.AttemptedAt).Milliseconds()
+	_ = s.history.RecordAttempt(ctx, attempt)
+}
+
+// waitForRateLimit takes the recipient token before the global one, so a send held back by a busy
+// recipient does not sit on a global token other recipients could use.
+func (s *sender) waitForRateLimit(ctx context.Context, phoneNumber string) error {
+	if s.recipientLimiter != nil {
+		if err := s.recipientLimiter.Wait(ctx, phoneNumber); err != nil {
+			return err
+		}
+	}
+
+	if s.globalLimiter != nil {
+		if err := s.globalLimiter.Wait(ctx, "global"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setHeaders signs with the subscription's own secret when it has one and with the configured
+// webhook.signing secrets otherwise.
+func (s *sender) setHeaders(req *http.Request, body *payload.Body, idempotencyKey string, subscription subscription.Subscription) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	secrets := s.config.Signing.Secrets
+	if subscription.Secret != "" {
+		secrets = []string{subscription.Secret}
+	}
+
+	req.Header.Set("Content-Type", body.ContentType)
+	for name, value := range body.Headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set(HeaderIdempotencyKey, idempotencyKey)
+	req.Header.Set(signature.HeaderWebhookId, uuid.New().String())
+	req.Header.Set(signature.HeaderTimestamp, timestamp)
+	if len(secrets) > 0 {
+		req.Header.Set(signature.HeaderSignature, signature.Header(secrets, timestamp, body.Data))
+	}
+}
+
+// IdempotencyKey derives a UUID from the outbox entry and subscription IDs, so it is the same for
+// every retry and for a re-send after a crash, but differs between subscriptions.
+func IdempotencyKey(outboxId, subscriptionId int64) string {
+	name := fmt.Sprintf("message-sender/outbox/%d/subscription/%d", outboxId, subscriptionId)
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(name)).String()
+}
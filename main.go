@@ -11,12 +11,16 @@ import (
 	"github.com/serhatYilmazz/message-sender/api"
 	"github.com/serhatYilmazz/message-sender/internal/cache"
 	"github.com/serhatYilmazz/message-sender/internal/config"
+	"github.com/serhatYilmazz/message-sender/internal/delivery"
 	"github.com/serhatYilmazz/message-sender/internal/message"
 	"github.com/serhatYilmazz/message-sender/internal/outbox"
 	"github.com/serhatYilmazz/message-sender/internal/scheduler"
+	"github.com/serhatYilmazz/message-sender/internal/settings"
+	"github.com/serhatYilmazz/message-sender/internal/subscription"
 	"github.com/serhatYilmazz/message-sender/internal/webhook"
 	"github.com/serhatYilmazz/message-sender/pkg/db"
 	"github.com/serhatYilmazz/message-sender/pkg/log"
+	"github.com/serhatYilmazz/message-sender/pkg/ratelimit"
 	"github.com/serhatYilmazz/message-sender/pkg/redis"
 	"os"
 	"os/signal"
@@ -64,33 +68,131 @@ func main() {
 		Logger: logger,
 	}
 
+	pgSubscriptionRepository := &subscription.PgRepository{
+		Db:     postgresDb,
+		Logger: logger,
+	}
+
+	pgDeliveryRepository := &delivery.PgRepository{
+		Db:     postgresDb,
+		Logger: logger,
+	}
+	deliveryService := delivery.NewService(pgDeliveryRepository, logger)
+
 	// Initialize cache repository and service
 	cacheRepository := cache.NewRedisRepository(redisClient, logger)
-	cacheService := cache.NewService(cacheRepository, cfg.RedisConfig, logger)
+	cacheService := cache.NewService(cacheRepository, deliveryService, cfg.RedisConfig, logger)
+
+	// Settings changed through the API are shared by every instance and win over the YAML values.
+	pgSettingsRepository := &settings.PgRepository{
+		Db:     postgresDb,
+		Logger: logger,
+	}
+	settingsService := settings.NewService(pgSettingsRepository, cfg.SchedulerConfig, logger)
+	schedulerSettings, err := settingsService.EnsureSettings(context.Background())
+	if err != nil {
+		logger.WithError(err).Error("error while loading scheduler settings, using the configured ones")
+	} else {
+		cfg.SchedulerConfig = schedulerSettings.ApplyTo(cfg.SchedulerConfig)
+	}
 
 	// Initialize services
-	outboxService := outbox.NewService(pgOutboxRepository, logger)
+	outboxService := outbox.NewService(pgOutboxRepository, cfg.SchedulerConfig, logger)
+	subscriptionService := subscription.NewService(pgSubscriptionRepository, logger)
+	if err := subscriptionService.EnsureDefault(context.Background(), cfg.WebhookConfig.URL); err != nil {
+		logger.WithError(err).Error("error while seeding the default webhook subscription")
+	}
 
-	webhookSender := webhook.NewSender(cfg.WebhookConfig, logger)
-	messageService := message.NewMessageService(pgMessageRepository, outboxService, logger)
+	rateLimitConfig := cfg.WebhookConfig.RateLimit
+	globalLimiter := ratelimit.New(redisClient, ratelimit.Rate{
+		PerSecond: rateLimitConfig.GlobalRps,
+		Burst:     rateLimitConfig.GlobalBurst,
+	}, "ratelimit:webhook", logger)
+	recipientLimiter := ratelimit.New(redisClient, ratelimit.Rate{
+		PerSecond: rateLimitConfig.PerRecipientRps,
+		Burst:     rateLimitConfig.PerRecipientBurst,
+	}, "ratelimit:webhook:recipient", logger)
+
+	webhookSender, err := webhook.NewSender(cfg.WebhookConfig, globalLimiter, recipientLimiter, deliveryService, logger)
+	if err != nil {
+		logger.Fatal("webhook sender configuration is invalid:", err)
+	}
+	var circuitBreaker webhook.CircuitBreaker
+	if cfg.WebhookConfig.CircuitBreaker.Enabled {
+		breakerSender := webhook.NewCircuitBreakerSender(webhookSender, cfg.WebhookConfig.CircuitBreaker, logger)
+		webhookSender, circuitBreaker = breakerSender, breakerSender
+	}
+
+	messageService := message.NewMessageService(pgMessageRepository, outboxService, cacheService, logger)
+
+	dispatchStream, err := scheduler.NewDispatchStream(redisClient, cfg.SchedulerConfig)
+	if err != nil {
+		logger.Fatal("scheduler configuration is invalid:", err)
+	}
+
+	// The listener only shortens the wait for new messages; without it the scheduler still
+	// picks them up on its next tick.
+	var wakeups <-chan struct{}
+	if cfg.SchedulerConfig.Listen {
+		listener, err := db.NewListener(cfg.DbConfig, outbox.NotifyChannel, logger)
+		if err != nil {
+			logger.WithError(err).Error("error while listening for outbox notifications, falling back to polling")
+		} else {
+			defer func() {
+				if err := listener.Close(); err != nil {
+					logger.WithError(err).Error("error closing postgres listener")
+				}
+			}()
+			wakeups = listener.Wakeups()
+		}
+	}
+
+	leaderElection, err := scheduler.NewLeaderElection(redisClient, cfg.SchedulerConfig, logger)
+	if err != nil {
+		logger.Fatal("scheduler configuration is invalid:", err)
+	}
 
 	// Initialize scheduler components with cache service
 	outboxScheduler := scheduler.NewScheduler(
 		cfg.SchedulerConfig,
+		cfg.WebhookConfig,
 		outboxService,
+		subscriptionService,
 		webhookSender,
+		circuitBreaker,
 		cacheService,
+		dispatchStream,
+		wakeups,
+		leaderElection,
 		logger,
 	)
 
-	schedulerManager := scheduler.NewManager(outboxScheduler, logger)
+	schedulerManager := scheduler.NewManager(outboxScheduler, cfg.SchedulerConfig.DrainTimeout, logger)
 	if cfg.SchedulerConfig.Enabled {
 		err := schedulerManager.StartScheduler(context.Background())
 		if err != nil {
 			logger.WithError(err).Error("error while starting scheduler on startup")
 		}
 	}
-	schedulerControlService := scheduler.NewControlService(schedulerManager, logger)
+	schedulerControlService := scheduler.NewControlService(schedulerManager, circuitBreaker, settingsService, leaderElection, cfg.SchedulerConfig.InstanceId, logger)
+
+	// Without the listener, settings changed on another instance are only picked up by the
+	// periodic reload.
+	var settingsChanges <-chan struct{}
+	settingsListener, err := db.NewListener(cfg.DbConfig, settings.NotifyChannel, logger)
+	if err != nil {
+		logger.WithError(err).Error("error while listening for scheduler settings changes")
+	} else {
+		defer func() {
+			if err := settingsListener.Close(); err != nil {
+				logger.WithError(err).Error("error closing postgres listener")
+			}
+		}()
+		settingsChanges = settingsListener.Wakeups()
+	}
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	defer stopWatching()
+	go scheduler.WatchSettings(watchCtx, settingsService, schedulerManager, settingsChanges, logger)
 
 	// Handle graceful shutdown
 	sigChan := make(chan os.Signal, 1)
@@ -103,7 +205,7 @@ func main() {
 	go func() {
 		defer wg.Done()
 		logger.Info("starting API server...")
-		api.NewMessageHandler(messageService, schedulerControlService, cacheService, logger)
+		api.NewMessageHandler(messageService, schedulerControlService, cacheService, outboxService, subscriptionService, cfg.CallbackConfig, logger)
 	}()
 
 	logger.Info("application started successfully. Use /api/messages/process-message-sender to control the scheduler")
@@ -116,6 +218,10 @@ func main() {
 	if err := schedulerManager.StopScheduler(); err != nil {
 		logger.WithError(err).Error("error stopping scheduler")
 	}
+	stopWatching()
+	if err := settingsService.ReportInstanceState(context.Background(), string(scheduler.StateStopped)); err != nil {
+		logger.WithError(err).Warn("error reporting the stopped scheduler")
+	}
 
 	// Wait for all goroutines to finish
 	wg.Wait()
@@ -0,0 +1,7 @@
+ALTER TABLE outbox
+    ADD COLUMN IF NOT EXISTS attempt_count   INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS last_error      TEXT,
+    ADD COLUMN IF NOT EXISTS last_attempt_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_outbox_sent_next_attempt_at ON outbox (sent, next_attempt_at);
@@ -0,0 +1,16 @@
+ALTER TABLE outbox
+    ADD COLUMN IF NOT EXISTS dead    BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS dead_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_outbox_dead_dead_at ON outbox (dead, dead_at);
+
+CREATE TABLE IF NOT EXISTS outbox_attempts
+(
+    id           BIGSERIAL PRIMARY KEY,
+    outbox_id    BIGINT    NOT NULL REFERENCES outbox (id) ON DELETE CASCADE,
+    attempt      INTEGER   NOT NULL,
+    error        TEXT      NOT NULL,
+    attempted_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_attempts_outbox_id ON outbox_attempts (outbox_id);
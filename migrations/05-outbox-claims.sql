@@ -0,0 +1,5 @@
+ALTER TABLE outbox
+    ADD COLUMN IF NOT EXISTS claimed_by       TEXT,
+    ADD COLUMN IF NOT EXISTS lease_expires_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_outbox_lease_expires_at ON outbox (lease_expires_at);
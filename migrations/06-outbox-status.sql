@@ -0,0 +1,45 @@
+ALTER TABLE outbox
+    ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'pending';
+
+UPDATE outbox
+SET status = CASE
+                 WHEN sent THEN 'delivered'
+                 WHEN dead THEN 'dead'
+                 WHEN claimed_by IS NOT NULL THEN 'in_flight'
+                 WHEN attempt_count > 0 THEN 'retrying'
+                 ELSE 'pending'
+    END;
+
+ALTER TABLE outbox
+    ADD CONSTRAINT chk_outbox_status
+        CHECK (status IN ('pending', 'in_flight', 'delivered', 'retrying', 'dead', 'cancelled'));
+
+DROP INDEX IF EXISTS idx_outbox_sent_created_at;
+DROP INDEX IF EXISTS idx_outbox_sent_next_attempt_at;
+DROP INDEX IF EXISTS idx_outbox_dead_dead_at;
+
+ALTER TABLE outbox
+    DROP COLUMN IF EXISTS sent,
+    DROP COLUMN IF EXISTS dead;
+
+CREATE INDEX IF NOT EXISTS idx_outbox_status_next_attempt_at ON outbox (status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_outbox_status_dead_at ON outbox (status, dead_at);
+
+CREATE TABLE IF NOT EXISTS outbox_events
+(
+    id          BIGSERIAL PRIMARY KEY,
+    outbox_id   BIGINT      NOT NULL REFERENCES outbox (id) ON DELETE CASCADE,
+    message_id  text        NOT NULL,
+    from_status VARCHAR(20),
+    to_status   VARCHAR(20) NOT NULL,
+    reason      TEXT,
+    instance_id TEXT,
+    created_at  TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_events_outbox_id ON outbox_events (outbox_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_outbox_events_message_id ON outbox_events (message_id, created_at);
+
+INSERT INTO outbox_events (outbox_id, message_id, from_status, to_status, reason, created_at)
+SELECT id, message_id, NULL, status, 'migrated from sent/dead flags', updated_at
+FROM outbox;
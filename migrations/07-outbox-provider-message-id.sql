@@ -0,0 +1,4 @@
+ALTER TABLE outbox
+    ADD COLUMN IF NOT EXISTS provider_message_id TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_outbox_provider_message_id ON outbox (provider_message_id);
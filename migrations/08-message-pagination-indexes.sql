@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS idx_messages_created_at_id ON messages (created_at, id);
+CREATE INDEX IF NOT EXISTS idx_messages_phone_number ON messages (phone_number);
@@ -0,0 +1,15 @@
+ALTER TABLE messages
+    ADD COLUMN IF NOT EXISTS send_at    TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP;
+
+ALTER TABLE outbox
+    ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP;
+
+ALTER TABLE outbox
+    DROP CONSTRAINT IF EXISTS chk_outbox_status;
+
+ALTER TABLE outbox
+    ADD CONSTRAINT chk_outbox_status
+        CHECK (status IN ('pending', 'in_flight', 'delivered', 'retrying', 'dead', 'cancelled', 'expired'));
+
+CREATE INDEX IF NOT EXISTS idx_outbox_status_expires_at ON outbox (status, expires_at) WHERE expires_at IS NOT NULL;
@@ -0,0 +1,34 @@
+CREATE TABLE IF NOT EXISTS webhook_subscriptions
+(
+    id           BIGSERIAL PRIMARY KEY,
+    url          TEXT        NOT NULL,
+    secret       TEXT,
+    enabled      BOOLEAN     NOT NULL DEFAULT true,
+    phone_prefix VARCHAR(20),
+    tag          VARCHAR(50),
+    event_types  TEXT[]      NOT NULL DEFAULT '{}',
+    created_at   TIMESTAMP            DEFAULT CURRENT_TIMESTAMP,
+    updated_at   TIMESTAMP            DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS outbox_deliveries
+(
+    outbox_id           BIGINT      NOT NULL REFERENCES outbox (id) ON DELETE CASCADE,
+    subscription_id     BIGINT      NOT NULL REFERENCES webhook_subscriptions (id) ON DELETE CASCADE,
+    status              VARCHAR(20) NOT NULL,
+    attempt_count       INT         NOT NULL DEFAULT 0,
+    last_error          TEXT,
+    provider_message_id TEXT,
+    last_attempt_at     TIMESTAMP,
+    delivered_at        TIMESTAMP,
+    PRIMARY KEY (outbox_id, subscription_id),
+    CONSTRAINT chk_outbox_deliveries_status CHECK (status IN ('delivered', 'failed'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_deliveries_subscription_id ON outbox_deliveries (subscription_id);
+
+ALTER TABLE outbox
+    ADD COLUMN IF NOT EXISTS event_type VARCHAR(50) NOT NULL DEFAULT 'message.created';
+
+ALTER TABLE messages
+    ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
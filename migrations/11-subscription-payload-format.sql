@@ -0,0 +1,3 @@
+ALTER TABLE webhook_subscriptions
+    ADD COLUMN IF NOT EXISTS payload_format   VARCHAR(30),
+    ADD COLUMN IF NOT EXISTS payload_template TEXT;
@@ -0,0 +1,5 @@
+ALTER TABLE outbox_deliveries
+    DROP CONSTRAINT IF EXISTS chk_outbox_deliveries_status;
+
+ALTER TABLE outbox_deliveries
+    ADD CONSTRAINT chk_outbox_deliveries_status CHECK (status IN ('delivered', 'failed', 'rejected'));
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS webhook_deliveries
+(
+    id                  BIGSERIAL PRIMARY KEY,
+    outbox_id           BIGINT    NOT NULL,
+    message_id          text      NOT NULL,
+    subscription_id     BIGINT    NOT NULL,
+    request_hash        CHAR(64)  NOT NULL,
+    status_code         INT,
+    latency_ms          BIGINT    NOT NULL,
+    response_body       TEXT,
+    provider_message_id TEXT,
+    error               TEXT,
+    delivered           BOOLEAN   NOT NULL DEFAULT false,
+    attempted_at        TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_message_id ON webhook_deliveries (message_id, attempted_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_provider_message_id ON webhook_deliveries (provider_message_id)
+    WHERE provider_message_id IS NOT NULL;
@@ -0,0 +1,5 @@
+ALTER TABLE messages
+    ADD COLUMN IF NOT EXISTS handset_status     VARCHAR(20),
+    ADD COLUMN IF NOT EXISTS handset_status_at  TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS handset_error_code VARCHAR(50),
+    ADD COLUMN IF NOT EXISTS handset_updated_at TIMESTAMP;
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS scheduler_settings
+(
+    id               SMALLINT PRIMARY KEY DEFAULT 1,
+    interval_ms      BIGINT    NOT NULL,
+    batch_size       INT       NOT NULL,
+    send_timeout_ms  BIGINT    NOT NULL,
+    drain_timeout_ms BIGINT    NOT NULL,
+    updated_at       TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT chk_scheduler_settings_single_row CHECK (id = 1)
+);
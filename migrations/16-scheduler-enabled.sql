@@ -0,0 +1,13 @@
+-- A NULL enabled flag defers to scheduler.enabled in the configuration, so upgrading does not
+-- change whether the scheduler runs.
+ALTER TABLE scheduler_settings
+    ADD COLUMN IF NOT EXISTS enabled BOOLEAN;
+
+CREATE TABLE IF NOT EXISTS scheduler_instances
+(
+    instance_id  VARCHAR(255) PRIMARY KEY,
+    state        VARCHAR(20) NOT NULL,
+    heartbeat_at TIMESTAMP   NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduler_instances_heartbeat_at ON scheduler_instances (heartbeat_at);
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"github.com/lib/pq"
+	"github.com/serhatYilmazz/message-sender/internal/config"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+// pingInterval keeps an idle listener connection checked, so a connection that died silently is
+// noticed and reestablished.
+const pingInterval = 90 * time.Second
+
+// Listener LISTENs on a Postgres channel over its own connection and turns notifications into
+// wake-ups. Wake-ups are coalesced: any number of notifications arriving while the previous one
+// has not been consumed yet results in a single wake-up.
+type Listener struct {
+	listener *pq.Listener
+	wakeups  chan struct{}
+	done     chan struct{}
+	logger   *logrus.Logger
+}
+
+func NewListener(cfg config.DbConfig, channel string, logger *logrus.Logger) (*Listener, error) {
+	listener := pq.NewListener(dsn(cfg), time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			logger.WithError(err).WithField("event", event).Warn("postgres listener connection event")
+		}
+	})
+
+	if err := listener.Listen(channel); err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+
+	l := &Listener{
+		listener: listener,
+		wakeups:  make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		logger:   logger,
+	}
+	go l.run()
+
+	logger.WithField("channel", channel).Info("listening for postgres notifications")
+	return l, nil
+}
+
+// Wakeups delivers a value after one or more notifications. A reconnect also counts, since
+// notifications sent while the connection was down are lost.
+func (l *Listener) Wakeups() <-chan struct{} {
+	return l.wakeups
+}
+
+func (l *Listener) Close() error {
+	close(l.done)
+	return l.listener.Close()
+}
+
+func (l *Listener) run() {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.done:
+			return
+		case _, ok := <-l.listener.Notify:
+			if !ok {
+				return
+			}
+			select {
+			case l.wakeups <- struct{}{}:
+			default:
+			}
+		case <-ticker.C:
+			if err := l.listener.Ping(); err != nil {
+				l.logger.WithError(err).Warn("postgres listener ping failed")
+			}
+		}
+	}
+}
@@ -8,11 +8,7 @@ import (
 )
 
 func NewPostgresDb(cfg config.DbConfig) (*sql.DB, error) {
-	dsn := fmt.Sprintf(
-		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DbName, cfg.SslMode,
-	)
-	db, err := sql.Open("postgres", dsn)
+	db, err := sql.Open("postgres", dsn(cfg))
 	if err != nil {
 		return nil, err
 	}
@@ -23,3 +19,10 @@ func NewPostgresDb(cfg config.DbConfig) (*sql.DB, error) {
 
 	return db, nil
 }
+
+func dsn(cfg config.DbConfig) string {
+	return fmt.Sprintf(
+		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DbName, cfg.SslMode,
+	)
+}
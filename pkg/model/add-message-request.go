@@ -1,6 +1,11 @@
 package model
 
+import "time"
+
 type AddMessageRequest struct {
-	Content              string `json:"content" validate:"required,max=20"`
-	RecipientPhoneNumber string `json:"recipientPhoneNumber" validate:"required"`
+	Content              string     `json:"content" validate:"required,max=20"`
+	RecipientPhoneNumber string     `json:"recipientPhoneNumber" validate:"required"`
+	Tags                 []string   `json:"tags,omitempty" validate:"omitempty,max=10,dive,required,max=50"`
+	SendAt               *time.Time `json:"sendAt,omitempty"`
+	ExpiresAt            *time.Time `json:"expiresAt,omitempty"`
 }
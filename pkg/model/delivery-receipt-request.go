@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// DeliveryReceiptRequest is a delivery receipt (DLR) posted by the SMS provider. MessageId is the
+// provider's message ID and Timestamp, when given, is when the handset status changed.
+type DeliveryReceiptRequest struct {
+	MessageId string     `json:"messageId" validate:"required"`
+	Status    string     `json:"status" validate:"required,oneof=delivered undelivered expired"`
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+	ErrorCode string     `json:"errorCode,omitempty" validate:"max=50"`
+}
@@ -0,0 +1,12 @@
+package model
+
+type FindMessagesRequest struct {
+	Limit       int    `query:"limit" validate:"omitempty,min=1,max=500"`
+	Cursor      string `query:"cursor"`
+	Status      string `query:"status" validate:"omitempty,oneof=pending in_flight delivered retrying dead cancelled expired"`
+	PhoneNumber string `query:"phoneNumber" validate:"omitempty,max=20"`
+	CreatedFrom string `query:"createdFrom" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	CreatedTo   string `query:"createdTo" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	Content     string `query:"content" validate:"omitempty,max=255"`
+	Sort        string `query:"sort" validate:"omitempty,oneof=asc desc"`
+}
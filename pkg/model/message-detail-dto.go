@@ -0,0 +1,36 @@
+package model
+
+import "time"
+
+type MessageDetailDto struct {
+	Id                string                    `json:"id"`
+	Content           string                    `json:"content"`
+	PhoneNumber       string                    `json:"phoneNumber"`
+	Tags              []string                  `json:"tags,omitempty"`
+	Status            string                    `json:"status,omitempty"`
+	AttemptCount      int                       `json:"attemptCount"`
+	LastError         *string                   `json:"lastError,omitempty"`
+	ProviderMessageId *string                   `json:"providerMessageId,omitempty"`
+	LastAttemptAt     *time.Time                `json:"lastAttemptAt,omitempty"`
+	NextAttemptAt     *time.Time                `json:"nextAttemptAt,omitempty"`
+	DeliveredAt       *time.Time                `json:"deliveredAt,omitempty"`
+	HandsetStatus     *string                   `json:"handsetStatus,omitempty"`
+	HandsetStatusAt   *time.Time                `json:"handsetStatusAt,omitempty"`
+	HandsetErrorCode  *string                   `json:"handsetErrorCode,omitempty"`
+	SendAt            *time.Time                `json:"sendAt,omitempty"`
+	ExpiresAt         *time.Time                `json:"expiresAt,omitempty"`
+	CreatedAt         time.Time                 `json:"createdAt"`
+	UpdatedAt         time.Time                 `json:"updatedAt"`
+	Deliveries        []SubscriptionDeliveryDto `json:"deliveries,omitempty"`
+}
+
+// SubscriptionDeliveryDto is the delivery state of a message towards one webhook subscription.
+type SubscriptionDeliveryDto struct {
+	SubscriptionId    int64      `json:"subscriptionId"`
+	Status            string     `json:"status"`
+	AttemptCount      int        `json:"attemptCount"`
+	LastError         *string    `json:"lastError,omitempty"`
+	ProviderMessageId *string    `json:"providerMessageId,omitempty"`
+	LastAttemptAt     *time.Time `json:"lastAttemptAt,omitempty"`
+	DeliveredAt       *time.Time `json:"deliveredAt,omitempty"`
+}
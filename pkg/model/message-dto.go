@@ -3,9 +3,16 @@ package model
 import "time"
 
 type MessageDto struct {
-	Id          string    `json:"id"`
-	Content     string    `json:"content"`
-	PhoneNumber string    `json:"phoneNumber"`
-	CreatedAt   time.Time `json:"-"`
-	UpdatedAt   time.Time `json:"-"`
+	Id               string     `json:"id"`
+	Content          string     `json:"content"`
+	PhoneNumber      string     `json:"phoneNumber"`
+	Tags             []string   `json:"tags,omitempty"`
+	Status           string     `json:"status,omitempty"`
+	HandsetStatus    *string    `json:"handsetStatus,omitempty"`
+	HandsetStatusAt  *time.Time `json:"handsetStatusAt,omitempty"`
+	HandsetErrorCode *string    `json:"handsetErrorCode,omitempty"`
+	SendAt           *time.Time `json:"sendAt,omitempty"`
+	ExpiresAt        *time.Time `json:"expiresAt,omitempty"`
+	CreatedAt        time.Time  `json:"createdAt"`
+	UpdatedAt        time.Time  `json:"-"`
 }
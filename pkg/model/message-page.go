@@ -0,0 +1,6 @@
+package model
+
+type MessagePage struct {
+	Items      []MessageDto `json:"items"`
+	NextCursor string       `json:"nextCursor,omitempty"`
+}
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// SchedulerConfigDto is the scheduler configuration in effect on every instance.
+type SchedulerConfigDto struct {
+	Interval     string    `json:"interval"`
+	BatchSize    int       `json:"batchSize"`
+	SendTimeout  string    `json:"sendTimeout"`
+	DrainTimeout string    `json:"drainTimeout"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
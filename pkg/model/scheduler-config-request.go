@@ -0,0 +1,10 @@
+package model
+
+// SchedulerConfigRequest changes the runtime scheduler settings; omitted fields keep their current
+// value. Durations use Go syntax such as "30s" or "2m".
+type SchedulerConfigRequest struct {
+	Interval     *string `json:"interval,omitempty"`
+	BatchSize    *int    `json:"batchSize,omitempty" validate:"omitempty,min=1,max=1000"`
+	SendTimeout  *string `json:"sendTimeout,omitempty"`
+	DrainTimeout *string `json:"drainTimeout,omitempty"`
+}
@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// SchedulerStatus reports the scheduler on the instance that answered next to the cluster-wide
+// desired state, the states other instances reported recently, and the circuit breaker state per
+// webhook subscription ID, for the subscriptions called since startup. DesiredEnabled is left out
+// when the stored state could not be read, Leader when leader election is disabled.
+type SchedulerStatus struct {
+	IsRunning       bool                      `json:"isRunning"`
+	State           string                    `json:"state"`
+	InstanceId      string                    `json:"instanceId"`
+	DesiredEnabled  *bool                     `json:"desiredEnabled,omitempty"`
+	Instances       []SchedulerInstanceStatus `json:"instances,omitempty"`
+	Leader          *SchedulerLeaderStatus    `json:"leader,omitempty"`
+	CircuitBreakers map[string]string         `json:"circuitBreakers,omitempty"`
+}
+
+// SchedulerInstanceStatus is the scheduler state an instance last reported.
+type SchedulerInstanceStatus struct {
+	InstanceId  string    `json:"instanceId"`
+	State       string    `json:"state"`
+	HeartbeatAt time.Time `json:"heartbeatAt"`
+}
+
+// SchedulerLeaderStatus is the instance allowed to claim outbox entries and when its lease expires
+// unless renewed. InstanceId is empty while no instance holds the lease.
+type SchedulerLeaderStatus struct {
+	InstanceId     string     `json:"instanceId,omitempty"`
+	LeaseExpiresAt *time.Time `json:"leaseExpiresAt,omitempty"`
+	IsThisInstance bool       `json:"isThisInstance"`
+}
@@ -0,0 +1,16 @@
+package model
+
+// SubscriptionRequest creates or replaces a webhook subscription. A nil Enabled means enabled and a
+// nil Secret keeps the current secret on update; an empty one removes it.
+type SubscriptionRequest struct {
+	Url         string   `json:"url" validate:"required,url"`
+	Secret      *string  `json:"secret,omitempty" validate:"omitempty,max=255"`
+	Enabled     *bool    `json:"enabled,omitempty"`
+	PhonePrefix string   `json:"phonePrefix,omitempty" validate:"omitempty,max=20"`
+	Tag         string   `json:"tag,omitempty" validate:"omitempty,max=50"`
+	EventTypes  []string `json:"eventTypes,omitempty" validate:"omitempty,dive,required,max=50"`
+	// PayloadFormat is one of default, template, cloudevents or cloudevents_binary; empty means the
+	// configured webhook.payload. PayloadTemplate is required for template.
+	PayloadFormat   string `json:"payloadFormat,omitempty" validate:"omitempty,oneof=default template cloudevents cloudevents_binary"`
+	PayloadTemplate string `json:"payloadTemplate,omitempty" validate:"omitempty,max=4096"`
+}
@@ -0,0 +1,43 @@
+package ratelimit
+
+import (
+	"context"
+
+	redisClient "github.com/serhatYilmazz/message-sender/pkg/redis"
+	"github.com/sirupsen/logrus"
+)
+
+// Limiter hands out tokens from one token bucket per key.
+type Limiter interface {
+	// Wait blocks until a token for key is available or ctx is done.
+	Wait(ctx context.Context, key string) error
+}
+
+type Rate struct {
+	PerSecond float64
+	Burst     int
+}
+
+// burst returns the bucket capacity, which is at least one token.
+func (r Rate) burst() int {
+	if r.Burst < 1 {
+		return 1
+	}
+	return r.Burst
+}
+
+// New returns a Limiter shared across replicas through Redis, falling back to an in-process bucket
+// whenever Redis is unavailable. A nil client gives a purely in-process limiter and a non-positive
+// rate gives nil, meaning no limit.
+func New(client *redisClient.Client, rate Rate, prefix string, logger *logrus.Logger) Limiter {
+	if rate.PerSecond <= 0 {
+		return nil
+	}
+
+	local := NewLocalLimiter(rate)
+	if client == nil {
+		return local
+	}
+
+	return NewRedisLimiter(client, rate, prefix, local, logger)
+}
@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// maxIdleBuckets bounds how many per-key buckets are kept before full ones are evicted.
+const maxIdleBuckets = 10000
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type localLimiter struct {
+	rate    Rate
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+func NewLocalLimiter(rate Rate) Limiter {
+	return &localLimiter{
+		rate:    rate,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+func (l *localLimiter) Wait(ctx context.Context, key string) error {
+	for {
+		wait := l.reserve(key, time.Now())
+		if wait <= 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// reserve takes a token for key if one is available and otherwise returns how long until the
+// next one is.
+func (l *localLimiter) reserve(key string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	burst := float64(l.rate.burst())
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxIdleBuckets {
+			l.evictFull(now)
+		}
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = min(burst, b.tokens+now.Sub(b.last).Seconds()*l.rate.PerSecond)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+
+	return time.Duration((1 - b.tokens) / l.rate.PerSecond * float64(time.Second))
+}
+
+// evictFull drops buckets that have refilled completely, since they behave exactly like new ones.
+func (l *localLimiter) evictFull(now time.Time) {
+	burst := float64(l.rate.burst())
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate.PerSecond >= burst {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	redisClient "github.com/serhatYilmazz/message-sender/pkg/redis"
+	"github.com/sirupsen/logrus"
+)
+
+// tokenBucketScript refills the bucket stored at KEYS[1] using the Redis server clock, so every
+// replica agrees on elapsed time, and either takes a token (returning 0) or returns the number of
+// milliseconds until one is available.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+else
+	wait = math.ceil((1 - tokens) * 1000 / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+return wait
+`)
+
+type redisLimiter struct {
+	client   *redisClient.Client
+	rate     Rate
+	prefix   string
+	fallback Limiter
+	logger   *logrus.Logger
+}
+
+func NewRedisLimiter(client *redisClient.Client, rate Rate, prefix string, fallback Limiter, logger *logrus.Logger) Limiter {
+	return &redisLimiter{
+		client:   client,
+		rate:     rate,
+		prefix:   prefix,
+		fallback: fallback,
+		logger:   logger,
+	}
+}
+
+func (l *redisLimiter) Wait(ctx context.Context, key string) error {
+	redisKey := l.prefix + ":" + key
+
+	for {
+		waitMs, err := tokenBucketScript.Run(ctx, l.client, []string{redisKey}, l.rate.PerSecond, l.rate.burst()).Int64()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			l.logger.WithContext(ctx).WithError(err).
+				WithField("key", redisKey).
+				Warn("redis rate limiter unavailable, falling back to in-process limiter")
+			return l.fallback.Wait(ctx, key)
+		}
+
+		if waitMs <= 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(time.Duration(waitMs) * time.Millisecond)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// acquireScript extends the lease at KEYS[1] when ARGV[1] holds it already and takes it when it
+// is free, returning 1 if ARGV[1] holds the lease afterwards.
+var acquireScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return 1
+end
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return 1
+end
+return 0
+`)
+
+// releaseScript deletes the lease at KEYS[1] only while ARGV[1] still holds it.
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// Lease is a key held by one owner at a time that expires unless its owner renews it.
+type Lease struct {
+	client *Client
+	key    string
+	ttl    time.Duration
+}
+
+func (c *Client) Lease(key string, ttl time.Duration) *Lease {
+	return &Lease{
+		client: c,
+		key:    key,
+		ttl:    ttl,
+	}
+}
+
+// Acquire takes the lease for owner, or renews it if owner holds it already, and reports whether
+// owner holds it now.
+func (l *Lease) Acquire(ctx context.Context, owner string) (bool, error) {
+	held, err := acquireScript.Run(ctx, l.client, []string{l.key}, owner, l.ttl.Milliseconds()).Int64()
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire lease %s: %w", l.key, err)
+	}
+	return held == 1, nil
+}
+
+func (l *Lease) Release(ctx context.Context, owner string) error {
+	if err := releaseScript.Run(ctx, l.client, []string{l.key}, owner).Err(); err != nil {
+		return fmt.Errorf("failed to release lease %s: %w", l.key, err)
+	}
+	return nil
+}
+
+// Holder returns the current owner of the lease and when it expires, or an empty owner when
+// nobody holds it.
+func (l *Lease) Holder(ctx context.Context) (string, time.Time, error) {
+	pipe := l.client.Pipeline()
+	get := pipe.Get(ctx, l.key)
+	ttl := pipe.PTTL(ctx, l.key)
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return "", time.Time{}, fmt.Errorf("failed to read lease %s: %w", l.key, err)
+	}
+
+	owner, err := get.Result()
+	if errors.Is(err, redis.Nil) {
+		return "", time.Time{}, nil
+	}
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to read lease %s: %w", l.key, err)
+	}
+
+	return owner, time.Now().Add(ttl.Val()), nil
+}
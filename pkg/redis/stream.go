@@ -0,0 +1,143 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Stream is a Redis Stream consumed through a single consumer group. Messages are acknowledged
+// explicitly, and ones left pending by a consumer that died can be claimed by another one.
+type Stream struct {
+	client *Client
+	key    string
+	group  string
+	maxLen int64
+}
+
+// Stream returns the stream stored at key, read through group. A positive maxLen caps the stream
+// length approximately on every publish.
+func (c *Client) Stream(key, group string, maxLen int64) *Stream {
+	return &Stream{
+		client: c,
+		key:    key,
+		group:  group,
+		maxLen: maxLen,
+	}
+}
+
+// EnsureGroup creates the stream and its consumer group if they do not exist yet.
+func (s *Stream) EnsureGroup(ctx context.Context) error {
+	err := s.client.XGroupCreateMkStream(ctx, s.key, s.group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create consumer group %s on stream %s: %w", s.group, s.key, err)
+	}
+	return nil
+}
+
+func (s *Stream) Publish(ctx context.Context, values map[string]any) (string, error) {
+	id, err := s.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: s.key,
+		MaxLen: s.maxLen,
+		Approx: s.maxLen > 0,
+		Values: values,
+	}).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to publish to stream %s: %w", s.key, err)
+	}
+	return id, nil
+}
+
+// Read returns up to count messages never delivered to any consumer of the group, waiting at most
+// block for the first one. It returns no messages and no error when the wait times out.
+func (s *Stream) Read(ctx context.Context, consumer string, count int64, block time.Duration) ([]redis.XMessage, error) {
+	streams, err := s.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    s.group,
+		Consumer: consumer,
+		Streams:  []string{s.key, ">"},
+		Count:    count,
+		Block:    block,
+	}).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read from stream %s: %w", s.key, err)
+	}
+
+	var messages []redis.XMessage
+	for _, stream := range streams {
+		messages = append(messages, stream.Messages...)
+	}
+	return messages, nil
+}
+
+func (s *Stream) Ack(ctx context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if err := s.client.XAck(ctx, s.key, s.group, ids...).Err(); err != nil {
+		return fmt.Errorf("failed to acknowledge messages on stream %s: %w", s.key, err)
+	}
+	return nil
+}
+
+// PendingMessage is a claimed message together with how often the group has delivered it.
+type PendingMessage struct {
+	redis.XMessage
+	Deliveries int64
+}
+
+// ClaimStuck finds up to count messages that have been pending for at least minIdle, which means
+// their consumer most likely died, and claims them for consumer.
+func (s *Stream) ClaimStuck(ctx context.Context, consumer string, minIdle time.Duration, count int64) ([]PendingMessage, error) {
+	pending, err := s.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: s.key,
+		Group:  s.group,
+		Idle:   minIdle,
+		Start:  "-",
+		End:    "+",
+		Count:  count,
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending messages on stream %s: %w", s.key, err)
+	}
+
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]string, 0, len(pending))
+	deliveries := make(map[string]int64, len(pending))
+	for _, message := range pending {
+		ids = append(ids, message.ID)
+		deliveries[message.ID] = message.RetryCount
+	}
+
+	// XCLAIM checks the idle time again, so a message another consumer claimed in the meantime
+	// is skipped. Claiming counts as one more delivery.
+	claimed, err := s.client.XClaim(ctx, &redis.XClaimArgs{
+		Stream:   s.key,
+		Group:    s.group,
+		Consumer: consumer,
+		MinIdle:  minIdle,
+		Messages: ids,
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim pending messages on stream %s: %w", s.key, err)
+	}
+
+	messages := make([]PendingMessage, 0, len(claimed))
+	for _, message := range claimed {
+		messages = append(messages, PendingMessage{
+			XMessage:   message,
+			Deliveries: deliveries[message.ID] + 1,
+		})
+	}
+	return messages, nil
+}
@@ -0,0 +1,107 @@
+// Package signature signs outgoing webhook requests and lets receivers verify them.
+//
+// A request carries the unix timestamp it was signed at in X-Timestamp and one or more
+// signatures in X-Signature, formatted as "v1=<hex>" and separated by commas. Each signature is
+// the HMAC-SHA256 of "<timestamp>.<body>" under one of the sender's active secrets, so secrets
+// can be rotated by running the old and the new one side by side.
+package signature
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderSignature = "X-Signature"
+	HeaderTimestamp = "X-Timestamp"
+	HeaderWebhookId = "X-Webhook-Id"
+
+	version = "v1"
+)
+
+var (
+	ErrMissingHeaders    = errors.New("signature headers are missing")
+	ErrInvalidTimestamp  = errors.New("signature timestamp is invalid")
+	ErrTimestampExpired  = errors.New("signature timestamp is outside the tolerance")
+	ErrSignatureMismatch = errors.New("no signature matches")
+)
+
+// Sign returns the hex encoded HMAC-SHA256 of "<timestamp>.<body>" under secret.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Header returns the X-Signature value carrying one signature per secret.
+func Header(secrets []string, timestamp string, body []byte) string {
+	signatures := make([]string, 0, len(secrets))
+	for _, secret := range secrets {
+		signatures = append(signatures, version+"="+Sign(secret, timestamp, body))
+	}
+	return strings.Join(signatures, ",")
+}
+
+// Verify checks that signatureHeader holds a signature of body made with any of secrets and that
+// timestampHeader is within tolerance of now. A zero tolerance disables the timestamp check.
+func Verify(secrets []string, signatureHeader, timestampHeader string, body []byte, tolerance time.Duration, now time.Time) error {
+	if signatureHeader == "" || timestampHeader == "" {
+		return ErrMissingHeaders
+	}
+
+	seconds, err := strconv.ParseInt(timestampHeader, 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+
+	if tolerance > 0 {
+		skew := now.Sub(time.Unix(seconds, 0))
+		if skew > tolerance || skew < -tolerance {
+			return ErrTimestampExpired
+		}
+	}
+
+	for _, part := range strings.Split(signatureHeader, ",") {
+		scheme, value, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found || scheme != version {
+			continue
+		}
+
+		received, err := hex.DecodeString(value)
+		if err != nil {
+			continue
+		}
+
+		for _, secret := range secrets {
+			expected, _ := hex.DecodeString(Sign(secret, timestampHeader, body))
+			if hmac.Equal(received, expected) {
+				return nil
+			}
+		}
+	}
+
+	return ErrSignatureMismatch
+}
+
+// VerifyRequest verifies an incoming *http.Request. The body is read and replaced, so handlers
+// can still consume it afterwards.
+func VerifyRequest(r *http.Request, secrets []string, tolerance time.Duration) error {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	_ = r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	return Verify(secrets, r.Header.Get(HeaderSignature), r.Header.Get(HeaderTimestamp), body, tolerance, time.Now())
+}